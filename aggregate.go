@@ -0,0 +1,186 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"math"
+	"time"
+)
+
+// An Aggregator groups queries into classes by the ID of their fingerprint and
+// aggregates their metrics, like pt-query-digest. Metrics are named values,
+// like "Query_time" and "Rows_examined" from a slow log; any name can be
+// used. An Aggregator is not safe for concurrent use.
+type Aggregator struct {
+	// Fingerprinter fingerprints queries passed to Add, and its ID method
+	// computes class Ids. If nil, Fingerprint and Id are used.
+	Fingerprinter *Fingerprinter
+
+	classes map[string]*Class
+	order   []*Class // in order first seen
+}
+
+// A Class is all the queries with the same fingerprint and their aggregated
+// metrics.
+type Class struct {
+	Id          string
+	Fingerprint string
+	Count       uint64 // number of queries
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Metrics     map[string]*Metric
+}
+
+// A Metric is the aggregate of all values of one metric in a Class. Count is
+// the number of values, which is less than Class.Count if some queries did
+// not have the metric. Mean, Median, P95, and P99 are set by
+// Aggregator.Classes. Like pt-query-digest, values are not kept but counted
+// in buckets that are 5% wide, so a Metric uses a bounded amount of memory
+// and Median, P95, and P99 are within 5% of the exact values.
+type Metric struct {
+	Count  uint64
+	Sum    float64
+	Min    float64
+	Max    float64
+	Mean   float64
+	Median float64
+	P95    float64 // 95th percentile
+	P99    float64 // 99th percentile
+
+	buckets map[int]uint64 // value count by bucket index
+}
+
+// Metric value buckets, like pt-query-digest: bucket 0 is values less than
+// minBucket, and each next bucket begins bucketSize times the previous one.
+// The last bucket has all greater values.
+const (
+	numBuckets = 1000
+	minBucket  = 0.000001
+	bucketSize = 1.05
+)
+
+var logBucketSize = math.Log(bucketSize)
+
+// bucketIndex returns the index of the bucket for val.
+func bucketIndex(val float64) int {
+	if val < minBucket {
+		return 0
+	}
+	i := 1 + int(math.Log(val/minBucket)/logBucketSize)
+	if i >= numBuckets {
+		i = numBuckets - 1
+	}
+	return i
+}
+
+// bucketValue returns the least value in bucket i.
+func bucketValue(i int) float64 {
+	if i == 0 {
+		return 0
+	}
+	return minBucket * math.Pow(bucketSize, float64(i-1))
+}
+
+// NewAggregator returns a new Aggregator.
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		classes: map[string]*Class{},
+	}
+}
+
+// Add fingerprints q and adds it and its metrics to its class. ts is when the
+// query was executed; it is ignored if zero.
+func (a *Aggregator) Add(q string, ts time.Time, metrics map[string]float64) {
+	fp := a.Fingerprinter
+	if fp == nil {
+		fp = defaultFingerprinter
+	}
+	a.AddFingerprint(fp.Fingerprint(q), ts, metrics)
+}
+
+// AddFingerprint is like Add but for a query already fingerprinted, like
+// slowlog.Event.Fingerprint. The class Id is computed with the IdHash of the
+// Fingerprinter.
+func (a *Aggregator) AddFingerprint(fingerprint string, ts time.Time, metrics map[string]float64) {
+	fp := a.Fingerprinter
+	if fp == nil {
+		fp = defaultFingerprinter
+	}
+	id := fp.ID(fingerprint).String()
+	c, ok := a.classes[id]
+	if !ok {
+		c = &Class{
+			Id:          id,
+			Fingerprint: fingerprint,
+			Metrics:     map[string]*Metric{},
+		}
+		a.classes[id] = c
+		a.order = append(a.order, c)
+	}
+
+	c.Count++
+	if !ts.IsZero() {
+		if c.FirstSeen.IsZero() || ts.Before(c.FirstSeen) {
+			c.FirstSeen = ts
+		}
+		if ts.After(c.LastSeen) {
+			c.LastSeen = ts
+		}
+	}
+
+	for name, val := range metrics {
+		m, ok := c.Metrics[name]
+		if !ok {
+			m = &Metric{Min: val, Max: val, buckets: map[int]uint64{}}
+			c.Metrics[name] = m
+		}
+		m.Count++
+		m.Sum += val
+		if val < m.Min {
+			m.Min = val
+		}
+		if val > m.Max {
+			m.Max = val
+		}
+		m.buckets[bucketIndex(val)]++
+	}
+}
+
+// Classes returns all classes in the order they were first seen with their
+// metric statistics set. It can be called again after adding more queries.
+func (a *Aggregator) Classes() []*Class {
+	for _, c := range a.order {
+		for _, m := range c.Metrics {
+			m.finalize()
+		}
+	}
+	return a.order
+}
+
+// finalize sets the statistics from the sum and buckets.
+func (m *Metric) finalize() {
+	if m.Count == 0 {
+		return
+	}
+	m.Mean = m.Sum / float64(m.Count)
+	m.Median = m.percentile(0.5)
+	m.P95 = m.percentile(0.95)
+	m.P99 = m.percentile(0.99)
+}
+
+// percentile returns the nearest-rank percentile p (0 < p <= 1) of the values:
+// the least value in the bucket with the value of that rank, but not less than
+// Min or greater than Max.
+func (m *Metric) percentile(p float64) float64 {
+	rank := uint64(math.Ceil(p * float64(m.Count)))
+	var n uint64
+	for i := 0; i < numBuckets; i++ {
+		n += m.buckets[i]
+		if n >= rank {
+			return math.Min(math.Max(bucketValue(i), m.Min), m.Max)
+		}
+	}
+	return m.Max
+}
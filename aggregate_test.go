@@ -0,0 +1,100 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-mysql/query"
+)
+
+func TestAggregator(t *testing.T) {
+	a := query.NewAggregator()
+	t0 := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 100 queries in one class with Query_time 1..100, and one query in another
+	// class.
+	for i := 1; i <= 100; i++ {
+		q := "SELECT c FROM t WHERE id=" + string(rune('0'+i%10))
+		a.Add(q, t0.Add(time.Duration(i)*time.Second), map[string]float64{"Query_time": float64(i)})
+	}
+	a.Add("SELECT * FROM t2", time.Time{}, map[string]float64{"Query_time": 0.5, "Rows_sent": 3})
+
+	classes := a.Classes()
+	if len(classes) != 2 {
+		t.Fatalf("got %d classes, expected 2", len(classes))
+	}
+
+	c := classes[0]
+	if c.Fingerprint != "select c from t where id=?" || c.Id != query.Id(c.Fingerprint) {
+		t.Errorf("got class %s %s", c.Id, c.Fingerprint)
+	}
+	if c.Count != 100 {
+		t.Errorf("got Count %d, expected 100", c.Count)
+	}
+	if !c.FirstSeen.Equal(t0.Add(time.Second)) || !c.LastSeen.Equal(t0.Add(100*time.Second)) {
+		t.Errorf("got FirstSeen %s LastSeen %s", c.FirstSeen, c.LastSeen)
+	}
+	m := c.Metrics["Query_time"]
+	expect := query.Metric{
+		Count:  100,
+		Sum:    5050,
+		Min:    1,
+		Max:    100,
+		Mean:   50.5,
+		Median: 50,
+		P95:    95,
+		P99:    99,
+	}
+	if m.Count != expect.Count || m.Sum != expect.Sum || m.Min != expect.Min || m.Max != expect.Max ||
+		m.Mean != expect.Mean || !approx(m.Median, expect.Median) || !approx(m.P95, expect.P95) || !approx(m.P99, expect.P99) {
+		t.Errorf("got:\n%+v\nexpected:\n%+v\n", *m, expect)
+	}
+
+	c = classes[1]
+	if c.Count != 1 || !c.FirstSeen.IsZero() || !c.LastSeen.IsZero() {
+		t.Errorf("got %+v", c)
+	}
+	if m := c.Metrics["Rows_sent"]; m.Min != 3 || m.Max != 3 || m.Median != 3 || m.P99 != 3 {
+		t.Errorf("got %+v", *m)
+	}
+
+	// More queries can be added after Classes.
+	a.AddFingerprint("select * from t2", time.Time{}, map[string]float64{"Query_time": 1.5})
+	classes = a.Classes()
+	if m := classes[1].Metrics["Query_time"]; classes[1].Count != 2 || m.Median != 0.5 || m.Max != 1.5 {
+		t.Errorf("got %+v", *m)
+	}
+}
+
+func TestAggregatorBuckets(t *testing.T) {
+	// Percentiles are within 5% for any number and range of values.
+	a := query.NewAggregator()
+	for i := 1; i <= 100000; i++ {
+		a.AddFingerprint("select ?", time.Time{}, map[string]float64{"Query_time": float64(i) / 1000, "Rows_sent": 0})
+	}
+	m := a.Classes()[0].Metrics["Query_time"]
+	if m.Min != 0.001 || m.Max != 100 || !approx(m.Median, 50) || !approx(m.P95, 95) || !approx(m.P99, 99) {
+		t.Errorf("got %+v", *m)
+	}
+	if m := a.Classes()[0].Metrics["Rows_sent"]; m.Min != 0 || m.Max != 0 || m.Median != 0 || m.P99 != 0 {
+		t.Errorf("got %+v", *m)
+	}
+
+	// Class Ids are computed with the Fingerprinter IdHash.
+	a = query.NewAggregator()
+	a.Fingerprinter = &query.Fingerprinter{IdHash: query.IdHashFNV1a}
+	a.Add("SELECT 1", time.Time{}, nil)
+	if c := a.Classes()[0]; c.Id != query.IdHashFNV1a.ID("select ?").String() {
+		t.Errorf("got Id %s, expected the FNV-1a ID", c.Id)
+	}
+}
+
+// approx returns true if got is the bucket value of expect: not greater, and
+// within 5%.
+func approx(got, expect float64) bool {
+	return got <= expect && got >= expect/1.05
+}
@@ -0,0 +1,225 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"strings"
+)
+
+// A StatementType is the type of a statement returned by Classify.
+type StatementType byte
+
+const (
+	StatementUnknown StatementType = iota
+
+	// Data manipulation
+	StatementSelect
+	StatementInsert
+	StatementUpdate
+	StatementDelete
+	StatementReplace
+	StatementLoadData
+	StatementCall
+
+	// Data definition
+	StatementCreate
+	StatementAlter
+	StatementDrop
+	StatementRename
+	StatementTruncate
+
+	// Data control
+	StatementGrant
+	StatementRevoke
+
+	// Transaction control
+	StatementBegin
+	StatementCommit
+	StatementRollback
+	StatementSavepoint
+	StatementLock
+
+	// Other
+	StatementShow
+	StatementSet
+	StatementUse
+	StatementExplain
+	StatementAdmin
+)
+
+var statementTypeName = map[StatementType]string{
+	StatementUnknown:   "UNKNOWN",
+	StatementSelect:    "SELECT",
+	StatementInsert:    "INSERT",
+	StatementUpdate:    "UPDATE",
+	StatementDelete:    "DELETE",
+	StatementReplace:   "REPLACE",
+	StatementLoadData:  "LOAD DATA",
+	StatementCall:      "CALL",
+	StatementCreate:    "CREATE",
+	StatementAlter:     "ALTER",
+	StatementDrop:      "DROP",
+	StatementRename:    "RENAME",
+	StatementTruncate:  "TRUNCATE",
+	StatementGrant:     "GRANT",
+	StatementRevoke:    "REVOKE",
+	StatementBegin:     "BEGIN",
+	StatementCommit:    "COMMIT",
+	StatementRollback:  "ROLLBACK",
+	StatementSavepoint: "SAVEPOINT",
+	StatementLock:      "LOCK",
+	StatementShow:      "SHOW",
+	StatementSet:       "SET",
+	StatementUse:       "USE",
+	StatementExplain:   "EXPLAIN",
+	StatementAdmin:     "ADMIN",
+}
+
+func (t StatementType) String() string {
+	return statementTypeName[t]
+}
+
+// IsDML returns true for data manipulation statements: SELECT, INSERT,
+// UPDATE, DELETE, REPLACE, LOAD DATA, and CALL.
+func (t StatementType) IsDML() bool {
+	return t >= StatementSelect && t <= StatementCall
+}
+
+// IsDDL returns true for data definition statements: CREATE, ALTER, DROP,
+// RENAME, and TRUNCATE.
+func (t StatementType) IsDDL() bool {
+	return t >= StatementCreate && t <= StatementTruncate
+}
+
+// IsDCL returns true for data control statements: GRANT and REVOKE.
+func (t StatementType) IsDCL() bool {
+	return t == StatementGrant || t == StatementRevoke
+}
+
+// IsTransaction returns true for transaction control statements: BEGIN,
+// START TRANSACTION, COMMIT, ROLLBACK, SAVEPOINT, RELEASE SAVEPOINT, and
+// LOCK and UNLOCK TABLES.
+func (t StatementType) IsTransaction() bool {
+	return t >= StatementBegin && t <= StatementLock
+}
+
+// firstWordType maps the first word of a statement to its type. Words that
+// need the next word, like START TRANSACTION, are handled in Classify.
+var firstWordType = map[string]StatementType{
+	"select":    StatementSelect,
+	"insert":    StatementInsert,
+	"update":    StatementUpdate,
+	"delete":    StatementDelete,
+	"replace":   StatementReplace,
+	"call":      StatementCall,
+	"create":    StatementCreate,
+	"alter":     StatementAlter,
+	"drop":      StatementDrop,
+	"rename":    StatementRename,
+	"truncate":  StatementTruncate,
+	"grant":     StatementGrant,
+	"revoke":    StatementRevoke,
+	"begin":     StatementBegin,
+	"commit":    StatementCommit,
+	"rollback":  StatementRollback,
+	"savepoint": StatementSavepoint,
+	"release":   StatementSavepoint,
+	"lock":      StatementLock,
+	"unlock":    StatementLock,
+	"show":      StatementShow,
+	"set":       StatementSet,
+	"use":       StatementUse,
+	"explain":   StatementExplain,
+	"describe":  StatementExplain,
+	"desc":      StatementExplain,
+	"analyze":   StatementAdmin,
+	"check":     StatementAdmin,
+	"checksum":  StatementAdmin,
+	"optimize":  StatementAdmin,
+	"repair":    StatementAdmin,
+	"flush":     StatementAdmin,
+	"kill":      StatementAdmin,
+	"purge":     StatementAdmin,
+	"reset":     StatementAdmin,
+	"shutdown":  StatementAdmin,
+}
+
+// Classify returns the type of statement q. Leading comments are ignored, and
+// /*! MySQL-specific code */ is treated as code, so "/*!40101 SET ... */" is
+// a SET statement. A WITH statement is the type of the statement that follows
+// the common table expressions, and a parenthesized statement like
+// "(SELECT ...) UNION (SELECT ...)" is the type of the first statement. The
+// slow log pseudo-statement "administrator command: Quit" is StatementAdmin.
+// StatementUnknown is returned if q is empty or the type is not known.
+func Classify(q string) StatementType {
+	typ, _ := classify(NewLexer(q))
+	return typ
+}
+
+// classify returns the type of the statement scanned by l and the word that
+// determines it, like SELECT in "(SELECT ...)" or "WITH cte AS (...) SELECT".
+func classify(l *Lexer) (StatementType, Token) {
+	for l.nextCode() {
+		t := l.Token()
+		if t.Type == TokenOperator && t.Text == "(" {
+			continue // (SELECT ...)
+		}
+		if t.Type != TokenWord {
+			return StatementUnknown, t
+		}
+		word := strings.ToLower(t.Text)
+		switch word {
+		case "load":
+			if next := nextWord(l); next == "data" || next == "xml" {
+				return StatementLoadData, t
+			}
+			return StatementUnknown, t
+		case "start":
+			if nextWord(l) == "transaction" {
+				return StatementBegin, t
+			}
+			return StatementUnknown, t
+		case "administrator":
+			if nextWord(l) == "command" {
+				return StatementAdmin, t
+			}
+			return StatementUnknown, t
+		case "with":
+			return classifyWith(l)
+		}
+		return firstWordType[word], t
+	}
+	return StatementUnknown, Token{}
+}
+
+// classifyWith returns the type of the statement after WITH cte AS (...) and
+// its verb.
+func classifyWith(l *Lexer) (StatementType, Token) {
+	depth := 0
+	for l.nextCode() {
+		t := l.Token()
+		switch {
+		case t.Type == TokenOperator && t.Text == "(":
+			depth++
+		case t.Type == TokenOperator && t.Text == ")":
+			depth--
+		case t.Type == TokenWord && depth == 0:
+			switch word := strings.ToLower(t.Text); word {
+			case "select", "insert", "update", "delete", "replace":
+				return firstWordType[word], t
+			}
+		}
+	}
+	return StatementUnknown, Token{}
+}
+
+// nextWord returns the next code token lowercased if it is a word, else an
+// empty string.
+func nextWord(l *Lexer) string {
+	if !l.nextCode() || l.Token().Type != TokenWord {
+		return ""
+	}
+	return strings.ToLower(l.Token().Text)
+}
@@ -0,0 +1,84 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		q string
+		t query.StatementType
+	}{
+		{"SELECT c FROM t WHERE id=1", query.StatementSelect},
+		{"  /* comment */ select 1", query.StatementSelect},
+		{"-- comment\n# another\nSELECT 1", query.StatementSelect},
+		{"(SELECT 1) UNION (SELECT 2)", query.StatementSelect},
+		{"/*!40001 SELECT */ 1", query.StatementSelect},
+		{"WITH cte AS (SELECT id FROM t) SELECT * FROM cte", query.StatementSelect},
+		{"WITH cte AS (SELECT id FROM t) UPDATE t2 JOIN cte USING (id) SET c=1", query.StatementUpdate},
+		{"INSERT INTO t VALUES (1)", query.StatementInsert},
+		{"insert into t select * from t2", query.StatementInsert},
+		{"UPDATE t SET c=1", query.StatementUpdate},
+		{"DELETE FROM t", query.StatementDelete},
+		{"REPLACE INTO t VALUES (1)", query.StatementReplace},
+		{"LOAD DATA INFILE '/tmp/foo.txt' INTO TABLE t", query.StatementLoadData},
+		{"CALL foo(1, 2, 3)", query.StatementCall},
+		{"CREATE TABLE t (id INT)", query.StatementCreate},
+		{"ALTER TABLE t ADD COLUMN c INT", query.StatementAlter},
+		{"DROP TABLE IF EXISTS t", query.StatementDrop},
+		{"RENAME TABLE t TO t2", query.StatementRename},
+		{"TRUNCATE TABLE t", query.StatementTruncate},
+		{"GRANT SELECT ON db.* TO 'u'@'%'", query.StatementGrant},
+		{"REVOKE ALL ON db.* FROM 'u'@'%'", query.StatementRevoke},
+		{"BEGIN", query.StatementBegin},
+		{"START TRANSACTION READ ONLY", query.StatementBegin},
+		{"COMMIT", query.StatementCommit},
+		{"ROLLBACK TO SAVEPOINT s1", query.StatementRollback},
+		{"SAVEPOINT s1", query.StatementSavepoint},
+		{"RELEASE SAVEPOINT s1", query.StatementSavepoint},
+		{"LOCK TABLES t READ", query.StatementLock},
+		{"UNLOCK TABLES", query.StatementLock},
+		{"(LOCK TABLES t READ)", query.StatementLock},
+		{"SHOW GLOBAL STATUS", query.StatementShow},
+		{"SET NAMES utf8", query.StatementSet},
+		{"/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */", query.StatementSet},
+		{"use `foo`", query.StatementUse},
+		{"EXPLAIN SELECT 1", query.StatementExplain},
+		{"DESCRIBE t", query.StatementExplain},
+		{"administrator command: Init DB", query.StatementAdmin},
+		{"FLUSH TABLES", query.StatementAdmin},
+		{"", query.StatementUnknown},
+		{"/* only a comment */", query.StatementUnknown},
+		{"'not a statement'", query.StatementUnknown},
+		{"FOO BAR", query.StatementUnknown},
+	}
+	for _, test := range tests {
+		if got := query.Classify(test.q); got != test.t {
+			t.Errorf("%s: got %s, expected %s", test.q, got, test.t)
+		}
+	}
+}
+
+func TestStatementTypeCategories(t *testing.T) {
+	if !query.StatementSelect.IsDML() || !query.StatementCall.IsDML() || query.StatementCreate.IsDML() {
+		t.Error("IsDML")
+	}
+	if !query.StatementCreate.IsDDL() || !query.StatementTruncate.IsDDL() || query.StatementGrant.IsDDL() {
+		t.Error("IsDDL")
+	}
+	if !query.StatementGrant.IsDCL() || !query.StatementRevoke.IsDCL() || query.StatementSet.IsDCL() {
+		t.Error("IsDCL")
+	}
+	if !query.StatementBegin.IsTransaction() || !query.StatementLock.IsTransaction() || query.StatementShow.IsTransaction() {
+		t.Error("IsTransaction")
+	}
+	if query.StatementLoadData.String() != "LOAD DATA" {
+		t.Errorf("got %s, expected LOAD DATA", query.StatementLoadData)
+	}
+}
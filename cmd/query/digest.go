@@ -0,0 +1,185 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-mysql/query"
+	"github.com/go-mysql/query/slowlog"
+)
+
+func runDigest(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var opt options
+	var limit int
+	fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opt.register(fs)
+	fs.IntVar(&limit, "limit", 20, "print only the top N classes, or all if 0")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := opt.check(); err != nil {
+		return err
+	}
+
+	fp := opt.fingerprinter(stderr)
+	a := query.NewAggregator()
+	a.Fingerprinter = fp
+	err := eachFile(fs.Args(), stdin, func(r io.Reader) error {
+		p := slowlog.NewParser(r)
+		p.Fingerprinter = fp
+		for {
+			e, err := p.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			a.AddFingerprint(e.Fingerprint, e.Ts, e.Metrics())
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	classes := rankClasses(a.Classes())
+	total := 0.0
+	for _, c := range classes {
+		total += queryTime(c).Sum
+	}
+	if limit > 0 && len(classes) > limit {
+		classes = classes[:limit]
+	}
+
+	switch opt.format {
+	case "json":
+		return digestJSON(stdout, classes)
+	case "csv":
+		return digestCSV(stdout, classes, total)
+	}
+	return digestText(stdout, classes, total)
+}
+
+// rankClasses sorts classes by total Query_time, highest first. Classes with
+// the same total stay in the order first seen.
+func rankClasses(classes []*query.Class) []*query.Class {
+	ranked := make([]*query.Class, len(classes))
+	copy(ranked, classes)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return queryTime(ranked[i]).Sum > queryTime(ranked[j]).Sum
+	})
+	return ranked
+}
+
+// queryTime returns the Query_time metric of c, or a zero metric if it has
+// none.
+func queryTime(c *query.Class) *query.Metric {
+	if m, ok := c.Metrics["Query_time"]; ok {
+		return m
+	}
+	return &query.Metric{}
+}
+
+func pct(n, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return n / total * 100
+}
+
+// digestText prints a profile like pt-query-digest.
+func digestText(w io.Writer, classes []*query.Class, total float64) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Rank\tQuery ID\tResponse time\t\tCalls\tR/Call\tP95\t  Query")
+	for i, c := range classes {
+		m := queryTime(c)
+		fmt.Fprintf(tw, "%d\t%s\t%.4f\t%.1f%%\t%d\t%.4f\t%.4f\t  %s\n",
+			i+1, c.Id, m.Sum, pct(m.Sum, total), c.Count, m.Mean, m.P95, c.Fingerprint)
+	}
+	return tw.Flush()
+}
+
+func digestCSV(w io.Writer, classes []*query.Class, total float64) error {
+	out := newOutput(w, "csv", []string{
+		"rank", "id", "count", "query_time_sum", "query_time_pct",
+		"query_time_mean", "query_time_p95", "query_time_max", "fingerprint",
+	})
+	for i, c := range classes {
+		m := queryTime(c)
+		err := out.write(
+			strconv.Itoa(i+1),
+			c.Id,
+			strconv.FormatUint(c.Count, 10),
+			formatFloat(m.Sum),
+			formatFloat(pct(m.Sum, total)),
+			formatFloat(m.Mean),
+			formatFloat(m.P95),
+			formatFloat(m.Max),
+			c.Fingerprint,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return out.flush()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// jsonClass is a query.Class in JSON output.
+type jsonClass struct {
+	Rank        int                           `json:"rank"`
+	Id          string                        `json:"id"`
+	Fingerprint string                        `json:"fingerprint"`
+	Count       uint64                        `json:"count"`
+	FirstSeen   *time.Time                    `json:"first_seen,omitempty"`
+	LastSeen    *time.Time                    `json:"last_seen,omitempty"`
+	Metrics     map[string]map[string]float64 `json:"metrics"`
+}
+
+func digestJSON(w io.Writer, classes []*query.Class) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for i, c := range classes {
+		jc := jsonClass{
+			Rank:        i + 1,
+			Id:          c.Id,
+			Fingerprint: c.Fingerprint,
+			Count:       c.Count,
+			Metrics:     map[string]map[string]float64{},
+		}
+		if !c.FirstSeen.IsZero() {
+			first, last := c.FirstSeen, c.LastSeen
+			jc.FirstSeen, jc.LastSeen = &first, &last
+		}
+		for name, m := range c.Metrics {
+			jc.Metrics[name] = map[string]float64{
+				"count":  float64(m.Count),
+				"sum":    m.Sum,
+				"min":    m.Min,
+				"max":    m.Max,
+				"mean":   m.Mean,
+				"median": m.Median,
+				"p95":    m.P95,
+				"p99":    m.P99,
+			}
+		}
+		if err := enc.Encode(jc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+// Command query fingerprints MySQL queries and reports slow logs.
+//
+// Usage:
+//
+//	query fingerprint [flags] [file...]
+//	query id [flags] [file...]
+//	query digest [flags] [slow.log...]
+//	query serve [flags]
+//
+// fingerprint and id read queries from the files, or STDIN if none or "-",
+// and print the fingerprint or ID of each query. Queries are one per line by
+// default; use -split nul for NUL-separated queries, like find -print0, or
+// -split semicolon for SQL scripts. digest parses slow logs and prints a
+// profile of query classes ranked by total Query_time, like pt-query-digest.
+// serve runs a query.Handler to fingerprint queries over HTTP:
+//
+//	curl -d '{"query": "SELECT 1"}' localhost:8080
+//
+// Output is text, json (one object per line), or csv with a header.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/go-mysql/query"
+)
+
+const usage = `Usage: query <command> [flags] [file...]
+
+Commands:
+  fingerprint  print the fingerprint of each query
+  id           print the ID of each query
+  digest       print a profile of query classes from slow logs
+  serve        fingerprint queries posted as JSON over HTTP
+
+Run query <command> -h for the command flags.
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, "query:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run runs the command in args, which does not include the program name.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return flag.ErrHelp
+	}
+	switch args[0] {
+	case "fingerprint", "id":
+		return runQueries(args[0], args[1:], stdin, stdout, stderr)
+	case "digest":
+		return runDigest(args[1:], stdin, stdout, stderr)
+	case "serve":
+		return runServe(args[1:], stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	fmt.Fprint(stderr, usage)
+	return fmt.Errorf("unknown command: %s", args[0])
+}
+
+// options are the flags common to all commands.
+type options struct {
+	replaceNumbers bool
+	debug          bool
+	debugFormat    string
+	format         string
+	dialect        string // only fingerprint, id, and serve
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.replaceNumbers, "replace-numbers", false, "replace numbers in words like db123 (Fingerprinter.ReplaceNumbersInWords)")
+	fs.BoolVar(&o.debug, "debug", false, "print fingerprint tracing to STDERR")
+	fs.StringVar(&o.debugFormat, "debug-format", "text", "tracing format: text or json")
+	fs.StringVar(&o.format, "format", "text", "output format: text, json, or csv")
+}
+
+// fingerprinter returns a Fingerprinter with the options. Tracing is written
+// to stderr.
+func (o *options) fingerprinter(stderr io.Writer) *query.Fingerprinter {
+	fp := &query.Fingerprinter{
+		ReplaceNumbersInWords: o.replaceNumbers,
+	}
+	if o.dialect == "postgresql" {
+		fp.Dialect = query.DialectPostgreSQL
+	}
+	if o.debug {
+		if o.debugFormat == "json" {
+			fp.Tracer = query.NewJSONTracer(stderr)
+		} else {
+			fp.Tracer = query.NewTextTracer(stderr)
+		}
+	}
+	return fp
+}
+
+func (o *options) check() error {
+	switch o.format {
+	case "text", "json", "csv":
+	default:
+		return fmt.Errorf("invalid -format %s: must be text, json, or csv", o.format)
+	}
+	switch o.debugFormat {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid -debug-format %s: must be text or json", o.debugFormat)
+	}
+	return checkDialect(o.dialect)
+}
+
+func checkDialect(dialect string) error {
+	switch dialect {
+	case "", "mysql", "postgresql":
+		return nil
+	}
+	return fmt.Errorf("invalid -dialect %s: must be mysql or postgresql", dialect)
+}
+
+func runQueries(cmd string, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var opt options
+	var split string
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opt.register(fs)
+	fs.StringVar(&split, "split", "line", "queries are separated by: line, nul, or semicolon")
+	fs.StringVar(&opt.dialect, "dialect", "mysql", "SQL dialect: mysql or postgresql")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := opt.check(); err != nil {
+		return err
+	}
+	switch split {
+	case "line", "nul", "semicolon":
+	default:
+		return fmt.Errorf("invalid -split %s: must be line, nul, or semicolon", split)
+	}
+
+	fp := opt.fingerprinter(stderr)
+	cols := []string{"fingerprint"}
+	if cmd == "id" {
+		cols = []string{"id", "fingerprint"}
+	}
+	out := newOutput(stdout, opt.format, cols)
+	err := eachFile(fs.Args(), stdin, func(r io.Reader) error {
+		return readQueries(r, split, func(q string) error {
+			f := fp.Fingerprint(q)
+			if cmd == "id" {
+				return out.write(query.Id(f), f)
+			}
+			return out.write(f)
+		})
+	})
+	if ferr := out.flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// eachFile calls fn with each file, or stdin if there are no files. A file
+// named "-" is stdin.
+func eachFile(files []string, stdin io.Reader, fn func(io.Reader) error) error {
+	if len(files) == 0 {
+		return fn(stdin)
+	}
+	for _, name := range files {
+		if name == "-" {
+			if err := fn(stdin); err != nil {
+				return err
+			}
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = fn(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// maxQuerySize is the maximum size of a line or NUL-separated query.
+const maxQuerySize = 256 << 20
+
+// readQueries calls fn with each query in r separated by split: line, nul,
+// or semicolon. Blank queries are skipped.
+func readQueries(r io.Reader, split string, fn func(q string) error) error {
+	if split == "semicolon" {
+		buf, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		for _, s := range query.Split(string(buf)) {
+			if err := fn(s.Text); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxQuerySize)
+	if split == "nul" {
+		s.Split(scanNul)
+	}
+	for s.Scan() {
+		q := s.Text()
+		if strings.TrimSpace(q) == "" {
+			continue
+		}
+		if err := fn(q); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// scanNul is a bufio.SplitFunc for NUL-separated data.
+func scanNul(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// output writes records in text, json, or csv format. Text is only the first
+// value, like the ID; json is one object per line with cols as keys; csv has
+// a header of cols.
+type output struct {
+	format string
+	cols   []string
+	w      *bufio.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+func newOutput(w io.Writer, format string, cols []string) *output {
+	o := &output{
+		format: format,
+		cols:   cols,
+		w:      bufio.NewWriter(w),
+	}
+	switch format {
+	case "csv":
+		o.csv = csv.NewWriter(o.w)
+		o.csv.Write(cols)
+	case "json":
+		o.json = json.NewEncoder(o.w)
+		o.json.SetEscapeHTML(false)
+	}
+	return o
+}
+
+// write writes one record. vals are in the order of cols.
+func (o *output) write(vals ...string) error {
+	if len(vals) != len(o.cols) {
+		return errors.New("output: wrong number of values")
+	}
+	switch o.format {
+	case "csv":
+		return o.csv.Write(vals)
+	case "json":
+		obj := make(map[string]string, len(vals))
+		for i, col := range o.cols {
+			obj[col] = vals[i]
+		}
+		return o.json.Encode(obj)
+	}
+	_, err := fmt.Fprintln(o.w, vals[0])
+	return err
+}
+
+func (o *output) flush() error {
+	if o.csv != nil {
+		o.csv.Flush()
+		if err := o.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return o.w.Flush()
+}
@@ -0,0 +1,121 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRunQueries(t *testing.T) {
+	tests := []struct {
+		args   []string
+		input  string
+		expect string
+	}{
+		{
+			[]string{"fingerprint"},
+			"SELECT c FROM t WHERE id=1\n\nselect * from db1.t where a='x'\n",
+			"select c from t where id=?\nselect * from db1.t where a=?\n",
+		},
+		{
+			[]string{"fingerprint", "-replace-numbers", "-split", "nul"},
+			"select 1 from db1.t\x00select\n2\x00",
+			"select ? from db?.t\nselect ?\n",
+		},
+		{
+			[]string{"fingerprint", "-split", "semicolon"},
+			"select 1; select 'a;b';\n",
+			"select ?\nselect ?\n",
+		},
+		{
+			[]string{"fingerprint", "-dialect", "postgresql"},
+			"SELECT * FROM \"T\" WHERE id = ANY(ARRAY[1,2]) AND c = $1::text\n",
+			"select * from \"t\" where id = any(array[?+]) and c = ?::text\n",
+		},
+		{
+			[]string{"id"},
+			"select sleep(2) from n\n",
+			"7F7D57ACDD8A346E\n",
+		},
+		{
+			[]string{"id", "-format", "csv"},
+			"select sleep(2) from n\n",
+			"id,fingerprint\n7F7D57ACDD8A346E,select sleep(?) from n\n",
+		},
+		{
+			[]string{"id", "-format", "json"},
+			"select sleep(2) from n\n",
+			`{"fingerprint":"select sleep(?) from n","id":"7F7D57ACDD8A346E"}` + "\n",
+		},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if err := run(test.args, strings.NewReader(test.input), &stdout, &stderr); err != nil {
+			t.Errorf("%v: %s", test.args, err)
+			continue
+		}
+		if got := stdout.String(); got != test.expect {
+			t.Errorf("%v:\ngot:\n%s\nexpected:\n%s\n", test.args, got, test.expect)
+		}
+	}
+}
+
+func TestRunDebug(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"fingerprint", "-debug", "-debug-format", "json"}
+	if err := run(args, strings.NewReader("select 1\n"), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if got, expect := stdout.String(), "select ?\n"; got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+	if n := strings.Count(stderr.String(), "\n"); n != 9 {
+		t.Errorf("got %d trace lines, expected 9:\n%s", n, stderr.String())
+	}
+}
+
+func TestRunDigest(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"digest", "-format", "json", "-limit", "2", "../../slowlog/testdata/mysql57.log", "../../slowlog/testdata/percona.log"}
+	if err := run(args, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	var classes []jsonClass
+	dec := json.NewDecoder(&stdout)
+	for dec.More() {
+		var c jsonClass
+		if err := dec.Decode(&c); err != nil {
+			t.Fatal(err)
+		}
+		classes = append(classes, c)
+	}
+	if len(classes) != 2 {
+		t.Fatalf("got %d classes, expected 2", len(classes))
+	}
+	if classes[0].Rank != 1 || classes[0].Metrics["Query_time"]["sum"] < classes[1].Metrics["Query_time"]["sum"] {
+		t.Errorf("classes not ranked by Query_time: %+v", classes)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"nope"},
+		{"fingerprint", "-format", "xml"},
+		{"id", "-split", "tab"},
+		{"fingerprint", "-dialect", "oracle"},
+		{"serve", "-dialect", "oracle"},
+		{"id", "-debug", "-debug-format", "xml"},
+		{"digest", "no-such-file.log"},
+		{"serve", "extra"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if err := run(args, strings.NewReader(""), &stdout, &stderr); err == nil {
+			t.Errorf("%v: no error", args)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-mysql/query"
+)
+
+func runServe(args []string, stderr io.Writer) error {
+	var opt options
+	var addr string
+	var maxBodySize int64
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&opt.replaceNumbers, "replace-numbers", false, "replace numbers in words like db123 (Fingerprinter.ReplaceNumbersInWords)")
+	fs.StringVar(&opt.dialect, "dialect", "mysql", "SQL dialect: mysql or postgresql")
+	fs.StringVar(&addr, "addr", "localhost:8080", "listen on this address")
+	fs.Int64Var(&maxBodySize, "max-body-size", query.DefaultMaxBodySize, "maximum request body size in bytes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("serve: unexpected arguments: %v", fs.Args())
+	}
+	if err := checkDialect(opt.dialect); err != nil {
+		return err
+	}
+
+	h := &query.Handler{
+		Fingerprinter: opt.fingerprinter(stderr),
+		MaxBodySize:   maxBodySize,
+	}
+	fmt.Fprintf(stderr, "Listening on %s\n", addr)
+	return http.ListenAndServe(addr, h)
+}
@@ -0,0 +1,226 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"strings"
+)
+
+// Distill returns a compact summary of q: its verbs and the tables it
+// references, like pt-query-digest --report-format distill. For example:
+//
+//	SELECT c FROM orders JOIN customers USING (id)  ->  SELECT orders customers
+//	INSERT INTO t SELECT * FROM db.t2               ->  INSERT SELECT t db.t2
+//	UPDATE inventory SET c=1 WHERE id=2             ->  UPDATE inventory
+//	ALTER TABLE t ADD COLUMN c INT                  ->  ALTER TABLE t
+//	SHOW CREATE TABLE t                             ->  SHOW CREATE TABLE t
+//	SET GLOBAL autocommit=0, @a=1                   ->  SET GLOBAL autocommit @a
+//	CALL sp(1, 2)                                   ->  CALL sp
+//	administrator command: Init DB                  ->  ADMIN INIT DB
+//
+// Verbs are uppercase and include SELECT for subqueries. Tables are returned
+// by Tables, qualified by database if the query qualifies them. An empty
+// string is returned if q is empty or its type is not known.
+func Distill(q string) string {
+	typ, verb := classify(NewLexer(q))
+	if typ == StatementUnknown {
+		return ""
+	}
+	toks := []Token{}
+	l := NewLexer(q)
+	for l.nextCode() {
+		toks = append(toks, l.Token())
+	}
+	// The statement tokens begin at the verb, after ( like (LOCK TABLES t READ)
+	stmt := toks
+	for len(stmt) > 0 && stmt[0].Start < verb.Start && stmt[0].Text == "(" {
+		stmt = stmt[1:]
+	}
+
+	var d []string
+	switch typ {
+	case StatementCreate, StatementAlter, StatementDrop, StatementRename, StatementTruncate:
+		d = distillDDL(typ, q, stmt)
+	case StatementShow:
+		d = distillShow(q, stmt)
+	case StatementSet:
+		d = distillSet(stmt)
+	case StatementUse:
+		d = []string{typ.String()}
+	case StatementCall:
+		d = []string{typ.String()}
+		p := &tableParser{toks: stmt, i: 1}
+		if t, ok := p.tableName(); ok {
+			d = append(d, t.String())
+		}
+	case StatementAdmin:
+		if strings.ToLower(verb.Text) == "administrator" {
+			if i := strings.Index(q, ":"); i >= 0 {
+				return "ADMIN " + strings.ToUpper(strings.Join(strings.Fields(q[i+1:]), " "))
+			}
+			return "ADMIN"
+		}
+		d = append([]string{strings.ToUpper(verb.Text)}, tableNames(Tables(q))...) // OPTIMIZE t
+	case StatementLock:
+		d = append([]string{strings.ToUpper(verb.Text)}, tableNames(Tables(q))...) // LOCK or UNLOCK
+	default:
+		d = append(distillVerbs(typ, toks), tableNames(Tables(q))...)
+	}
+	return strings.Join(d, " ")
+}
+
+// distillVerbs returns the verb of typ and SELECT if q has a subquery or
+// INSERT ... SELECT. EXPLAIN also returns the verb of the explained statement.
+func distillVerbs(typ StatementType, toks []Token) []string {
+	verbs := []string{typ.String()}
+	for i, t := range toks {
+		if i == 0 || t.Type != TokenWord {
+			continue
+		}
+		word := strings.ToLower(t.Text)
+		switch word {
+		case "select", "insert", "update", "delete", "replace":
+		default:
+			continue
+		}
+		if i+1 < len(toks) && toks[i+1].Type == TokenOperator && toks[i+1].Text == "(" {
+			continue // INSERT() and REPLACE() functions
+		}
+		if prev := strings.ToLower(toks[i-1].Text); prev == "for" || prev == "key" {
+			continue // FOR UPDATE, ON DUPLICATE KEY UPDATE
+		}
+		verb := strings.ToUpper(word)
+		seen := false
+		for _, v := range verbs {
+			seen = seen || v == verb
+		}
+		if !seen {
+			verbs = append(verbs, verb)
+		}
+	}
+	return verbs
+}
+
+// ddlObjects are the words after CREATE, ALTER, etc. that say what kind of
+// object the statement is for.
+var ddlObjects = map[string]bool{
+	"database": true, "event": true, "function": true, "index": true,
+	"procedure": true, "schema": true, "server": true, "table": true,
+	"tables": true, "tablespace": true, "trigger": true, "user": true,
+	"view": true,
+}
+
+// distillDDL returns the verb, object, and names, like ALTER TABLE t. Tables
+// are returned for TABLE, INDEX, and VIEW; for other objects the name is
+// returned.
+func distillDDL(typ StatementType, q string, toks []Token) []string {
+	d := []string{typ.String()}
+	obj := ""
+	i := 1
+	for ; i < len(toks); i++ {
+		if t := toks[i]; t.Type == TokenWord && ddlObjects[strings.ToLower(t.Text)] {
+			obj = strings.ToLower(t.Text)
+			break
+		}
+	}
+	if obj == "" && typ == StatementTruncate {
+		obj, i = "table", 0 // TRUNCATE t
+	}
+	if obj == "" {
+		return d
+	}
+	d = append(d, strings.ToUpper(obj))
+	switch obj {
+	case "table", "tables", "index":
+		return append(d, tableNames(Tables(q))...)
+	case "view":
+		// The view and the tables it selects from
+		d = append(d, objectName(toks, i+1)...)
+		return append(d, tableNames(Tables(q))...)
+	}
+	return append(d, objectName(toks, i+1)...)
+}
+
+// distillShow returns SHOW and the words that say what is shown, like SHOW
+// GLOBAL STATUS, and the tables or object name.
+func distillShow(q string, toks []Token) []string {
+	d := []string{"SHOW"}
+	for i := 1; i < len(toks); i++ {
+		t := toks[i]
+		if t.Type != TokenWord {
+			break
+		}
+		word := strings.ToLower(t.Text)
+		if wordIn(word, "from", "in", "like", "where", "for") {
+			break
+		}
+		d = append(d, strings.ToUpper(word))
+		if strings.ToLower(toks[i-1].Text) == "create" && ddlObjects[word] {
+			return append(d, objectName(toks, i+1)...) // SHOW CREATE TABLE t
+		}
+	}
+	return append(d, tableNames(Tables(q))...)
+}
+
+// distillSet returns SET and the scope and name of each variable, like SET
+// GLOBAL autocommit @a.
+func distillSet(toks []Token) []string {
+	d := []string{"SET"}
+	depth := 0
+	start := true // start of a variable assignment
+	for i := 1; i < len(toks); i++ {
+		t := toks[i]
+		if t.Type == TokenOperator {
+			switch t.Text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			case ",":
+				start = depth == 0
+			}
+			if t.Text != "@" {
+				continue
+			}
+		}
+		if !start {
+			continue
+		}
+		if t.Type == TokenWord && wordIn(t.Text, "global", "session", "local", "persist", "persist_only") {
+			d = append(d, strings.ToUpper(t.Text))
+			continue
+		}
+		// The name is the tokens that are not separated by space, like
+		// @@session.sql_mode, up to the = or value.
+		name := t.Text
+		end := t.End
+		for i+1 < len(toks) && toks[i+1].Start == end && toks[i+1].Text != "=" && toks[i+1].Text != ":=" {
+			i++
+			name += toks[i].Text
+			end = toks[i].End
+		}
+		d = append(d, name)
+		start = false
+	}
+	return d
+}
+
+// objectName returns the name, or db.name, at toks[i] after IF [NOT] EXISTS.
+func objectName(toks []Token, i int) []string {
+	p := &tableParser{toks: toks, i: i}
+	p.skipWords("if", "not", "exists")
+	if t, ok := p.tableName(); ok {
+		return []string{t.String()}
+	}
+	return nil
+}
+
+func tableNames(tables []Table) []string {
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.String()
+	}
+	return names
+}
@@ -0,0 +1,59 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestDistill(t *testing.T) {
+	tests := []struct {
+		q string
+		d string
+	}{
+		{"SELECT c FROM orders JOIN customers USING (id)", "SELECT orders customers"},
+		{"select * from t1 where id in (select id from db.t2 where c=(select max(c) from t3))", "SELECT t1 db.t2 t3"},
+		{"INSERT INTO t SELECT * FROM db.t2 ON DUPLICATE KEY UPDATE c=1", "INSERT SELECT t db.t2"},
+		{"insert into t (c) values (insert('abc', 1, 1, 'x'))", "INSERT t"},
+		{"UPDATE inventory SET c=1 WHERE id=2", "UPDATE inventory"},
+		{"UPDATE t1 JOIN t2 ON t1.id=t2.id SET t1.c=(SELECT 1 FROM t3)", "UPDATE SELECT t1 t2 t3"},
+		{"DELETE t1 FROM t1 JOIN t2 ON t1.id=t2.id", "DELETE t1 t2"},
+		{"SELECT c FROM t WHERE id=1 FOR UPDATE", "SELECT t"},
+		{"WITH cte AS (SELECT * FROM t1) SELECT * FROM cte JOIN t2", "SELECT t1 t2"},
+		{"ALTER TABLE db.t ADD COLUMN c INT", "ALTER TABLE db.t"},
+		{"CREATE TABLE IF NOT EXISTS t (id INT)", "CREATE TABLE t"},
+		{"DROP TABLE t1, t2", "DROP TABLE t1 t2"},
+		{"CREATE INDEX idx ON t (c)", "CREATE INDEX t"},
+		{"TRUNCATE t", "TRUNCATE TABLE t"},
+		{"CREATE DATABASE IF NOT EXISTS `db`", "CREATE DATABASE db"},
+		{"CREATE OR REPLACE VIEW v AS SELECT * FROM t", "CREATE VIEW v t"},
+		{"CREATE ALGORITHM=MERGE DEFINER=`u`@`h` SQL SECURITY DEFINER VIEW db.v AS SELECT 1", "CREATE VIEW db.v"},
+		{"SHOW CREATE TABLE db.t", "SHOW CREATE TABLE db.t"},
+		{"SHOW GLOBAL STATUS LIKE 'Threads%'", "SHOW GLOBAL STATUS"},
+		{"SHOW FULL COLUMNS FROM t", "SHOW FULL COLUMNS t"},
+		{"SET GLOBAL autocommit=0, @a=1", "SET GLOBAL autocommit @a"},
+		{"SET @@session.sql_mode='', NAMES utf8", "SET @@session.sql_mode NAMES"},
+		{"/*!40101 SET character_set_client = utf8 */", "SET character_set_client"},
+		{"USE db", "USE"},
+		{"CALL db.sp(1, 2)", "CALL db.sp"},
+		{"administrator command: Init DB", "ADMIN INIT DB"},
+		{"OPTIMIZE TABLE t1, t2", "OPTIMIZE t1 t2"},
+		{"LOCK TABLES t READ", "LOCK t"},
+		{"(LOCK TABLES t READ)", "LOCK t"},
+		{"((OPTIMIZE TABLE t1))", "OPTIMIZE t1"},
+		{"(SELECT c FROM t1) UNION (SELECT c FROM t2)", "SELECT t1 t2"},
+		{"COMMIT", "COMMIT"},
+		{"EXPLAIN SELECT * FROM t", "EXPLAIN SELECT t"},
+		{"", ""},
+		{"foo bar", ""},
+	}
+	for _, test := range tests {
+		if got := query.Distill(test.q); got != test.d {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s\n", test.q, got, test.d)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DefaultMaxBodySize is the default Handler.MaxBodySize: 16 MiB.
+const DefaultMaxBodySize = 16 << 20
+
+// A Handler is an http.Handler that fingerprints queries for services that
+// are not written in Go. It accepts a POST with a JSON object of one query or
+// a batch of queries:
+//
+//	{"query": "SELECT c FROM t WHERE id=1"}
+//	{"queries": ["SELECT c FROM t WHERE id=1", "DELETE FROM t"]}
+//
+// and responds with one result or {"results": [...]} in the same order:
+//
+//	{"fingerprint": "select c from t where id=?", "id": "CB5621E548E5497F",
+//	 "type": "SELECT", "tables": ["t"]}
+//
+// The ID is computed with Fingerprinter.IdHash, so by default it is the same
+// as Id. Tables are qualified by database if the query qualifies them, like
+// db.t. Errors are a JSON object like {"error": "..."} with status 400 for an
+// invalid request, 405 if not a POST, or 413 if the body is too large.
+type Handler struct {
+	// Fingerprinter fingerprints queries. If nil, Fingerprint is used. Its
+	// Dialect and SQLMode are also used to find the type and tables.
+	Fingerprinter *Fingerprinter
+
+	// MaxBodySize is the maximum request body size in bytes. If zero,
+	// DefaultMaxBodySize is used.
+	MaxBodySize int64
+}
+
+type handlerRequest struct {
+	Query   *string  `json:"query"`
+	Queries []string `json:"queries"`
+}
+
+type handlerResult struct {
+	Fingerprint string   `json:"fingerprint"`
+	Id          string   `json:"id"`
+	Type        string   `json:"type"`
+	Tables      []string `json:"tables"`
+}
+
+type handlerError struct {
+	Error string `json:"error"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		writeJSON(w, http.StatusMethodNotAllowed, handlerError{"method not allowed: " + r.Method})
+		return
+	}
+
+	max := h.MaxBodySize
+	if max <= 0 {
+		max = DefaultMaxBodySize
+	}
+	body := http.MaxBytesReader(w, r.Body, max)
+	var req handlerRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		// MaxBytesReader does not have an error type; it returns this error
+		// after reading max bytes.
+		if err.Error() == "http: request body too large" {
+			writeJSON(w, http.StatusRequestEntityTooLarge, handlerError{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, handlerError{"invalid JSON: " + err.Error()})
+		return
+	}
+
+	switch {
+	case req.Query != nil && req.Queries != nil:
+		writeJSON(w, http.StatusBadRequest, handlerError{"only one of query or queries is allowed"})
+	case req.Query != nil:
+		writeJSON(w, http.StatusOK, h.result(*req.Query))
+	case req.Queries != nil:
+		res := struct {
+			Results []handlerResult `json:"results"`
+		}{
+			Results: make([]handlerResult, len(req.Queries)),
+		}
+		for i, q := range req.Queries {
+			res.Results[i] = h.result(q)
+		}
+		writeJSON(w, http.StatusOK, res)
+	default:
+		writeJSON(w, http.StatusBadRequest, handlerError{"query or queries is required"})
+	}
+}
+
+func (h *Handler) result(q string) handlerResult {
+	fp := h.Fingerprinter
+	if fp == nil {
+		fp = defaultFingerprinter
+	}
+	f := fp.Fingerprint(q)
+	typ, _ := classify(fp.newLexer(q))
+	return handlerResult{
+		Fingerprint: f,
+		Id:          fp.ID(f).String(),
+		Type:        typ.String(),
+		Tables:      tableNames(parseTables(fp.newLexer(q))),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
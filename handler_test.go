@@ -0,0 +1,130 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestHandler(t *testing.T) {
+	ts := httptest.NewServer(&query.Handler{})
+	defer ts.Close()
+
+	tests := []struct {
+		method string
+		body   string
+		status int
+		expect string
+	}{
+		{
+			"POST",
+			`{"query": "SELECT c FROM t WHERE id=1"}`,
+			http.StatusOK,
+			`{"fingerprint":"select c from t where id=?","id":"CB5621E548E5497F","type":"SELECT","tables":["t"]}`,
+		},
+		{
+			"POST",
+			`{"queries": ["select c from t where id=2", "DELETE FROM db.t", "foo"]}`,
+			http.StatusOK,
+			`{"results":[` +
+				`{"fingerprint":"select c from t where id=?","id":"CB5621E548E5497F","type":"SELECT","tables":["t"]},` +
+				`{"fingerprint":"delete from db.t","id":"087FD1A86242388B","type":"DELETE","tables":["db.t"]},` +
+				`{"fingerprint":"foo","id":"` + query.Id("foo") + `","type":"UNKNOWN","tables":[]}]}`,
+		},
+		{
+			"POST",
+			`{"queries": []}`,
+			http.StatusOK,
+			`{"results":[]}`,
+		},
+		{
+			"POST",
+			`{"query": "select`,
+			http.StatusBadRequest,
+			`{"error":"invalid JSON: unexpected EOF"}`,
+		},
+		{
+			"POST",
+			`{}`,
+			http.StatusBadRequest,
+			`{"error":"query or queries is required"}`,
+		},
+		{
+			"GET",
+			"",
+			http.StatusMethodNotAllowed,
+			`{"error":"method not allowed: GET"}`,
+		},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, ts.URL, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != test.status {
+			t.Errorf("%s %s: got status %d, expected %d", test.method, test.body, resp.StatusCode, test.status)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: got Content-Type %s", test.method, test.body, ct)
+		}
+		if got := strings.TrimSpace(string(body)); got != test.expect {
+			t.Errorf("got:\n%s\nexpected:\n%s\n", got, test.expect)
+		}
+	}
+}
+
+func TestHandlerOptions(t *testing.T) {
+	h := &query.Handler{MaxBodySize: 32}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"query": "SELECT c FROM t WHERE id IN (1, 2, 3, 4)"}`))
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %d, expected %d: %s", w.Code, http.StatusRequestEntityTooLarge, w.Body.String())
+	}
+
+	// Options are used
+	h = &query.Handler{Fingerprinter: &query.Fingerprinter{ReplaceNumbersInWords: true, IdHash: query.IdHashFNV1a}}
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"query": "select * from db1.t"}`))
+	h.ServeHTTP(w, r)
+	expect := `{"fingerprint":"select * from db?.t","id":"` + query.IdHashFNV1a.ID("select * from db?.t").String() + `","type":"SELECT","tables":["db1.t"]}`
+	if got := strings.TrimSpace(w.Body.String()); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+	// Type and tables are found with the SQL mode and dialect
+	tests := []struct {
+		fp     *query.Fingerprinter
+		q      string
+		tables string
+	}{
+		{&query.Fingerprinter{SQLMode: query.ANSIQuotes}, `INSERT INTO \"My Table\" VALUES (1)`, `["My Table"]`},
+		{&query.Fingerprinter{Dialect: query.DialectPostgreSQL}, `INSERT INTO t SELECT $$it's$$ FROM t2`, `["t","t2"]`},
+	}
+	for _, test := range tests {
+		h = &query.Handler{Fingerprinter: test.fp}
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest("POST", "/", strings.NewReader(`{"query": "`+test.q+`"}`))
+		h.ServeHTTP(w, r)
+		expect := `"type":"INSERT","tables":` + test.tables + `}`
+		if got := strings.TrimSpace(w.Body.String()); !strings.HasSuffix(got, expect) {
+			t.Errorf("%s\ngot:\n%s\nexpected suffix:\n%s\n", test.q, got, expect)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"strings"
+)
+
+// normalizeHint returns the /*+ optimizer hints */ in h normalized like a
+// fingerprint: lowercase, values replaced with ?, and space collapsed. Quoted
+// values are parsed with mode.
+func normalizeHint(h string, mode SQLMode) string {
+	h = strings.TrimSuffix(strings.TrimPrefix(h, "/*+"), "*/")
+	buf := make([]byte, 0, len(h)+6)
+	buf = append(buf, "/*+"...)
+	space := true
+	l := NewLexer(h)
+	l.SQLMode = mode
+	for l.Next() {
+		t := l.Token()
+		switch t.Type {
+		case TokenSpace, TokenComment:
+			space = true
+			continue
+		}
+		if space {
+			buf = append(buf, ' ')
+			space = false
+		}
+		switch t.Type {
+		case TokenNumber, TokenHex, TokenBit, TokenString:
+			buf = append(buf, '?')
+		default:
+			buf = append(buf, strings.ToLower(t.Text)...)
+		}
+	}
+	return string(append(buf, " */"...))
+}
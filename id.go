@@ -0,0 +1,134 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// An IdHash is the hash algorithm used to compute query IDs.
+type IdHash int
+
+const (
+	IdHashMD5    IdHash = iota // right-most 8 bytes of MD5, the same as Id
+	IdHashFNV1a                // 64-bit FNV-1a
+	IdHashSHA256               // left-most 8 bytes of SHA-256
+	IdHashSpooky               // 64-bit SpookyHash V2, the same as the ProxySQL digest
+)
+
+var idHashName = map[IdHash]string{
+	IdHashMD5:    "md5",
+	IdHashFNV1a:  "fnv1a",
+	IdHashSHA256: "sha256",
+	IdHashSpooky: "spooky",
+}
+
+func (h IdHash) String() string {
+	if s, ok := idHashName[h]; ok {
+		return s
+	}
+	return "IdHash(" + strconv.Itoa(int(h)) + ")"
+}
+
+// ID returns the ID of fingerprint computed with h. Unknown hashes use MD5.
+func (h IdHash) ID(fingerprint string) ID {
+	switch h {
+	case IdHashFNV1a:
+		// Inlined hash/fnv to not allocate.
+		const (
+			offset64 = 14695981039346656037
+			prime64  = 1099511628211
+		)
+		var sum uint64 = offset64
+		for i := 0; i < len(fingerprint); i++ {
+			sum ^= uint64(fingerprint[i])
+			sum *= prime64
+		}
+		return ID(sum)
+	case IdHashSHA256:
+		sum := sha256.Sum256([]byte(fingerprint))
+		return ID(binary.BigEndian.Uint64(sum[:8]))
+	case IdHashSpooky:
+		return ID(spookyHash64([]byte(fingerprint), 0))
+	}
+	sum := md5.Sum([]byte(fingerprint))
+	return ID(binary.BigEndian.Uint64(sum[8:]))
+}
+
+// An ID is a numeric query ID. With IdHashMD5, ID.String is the same as Id.
+// IDs are stored in databases as BIGINT: the value is cast to int64, so IDs
+// greater than math.MaxInt64 are negative, which round-trips with Scan.
+type ID uint64
+
+// String returns the ID as 16 uppercase hex characters, like Id.
+func (id ID) String() string {
+	return fmt.Sprintf("%016X", uint64(id))
+}
+
+// ParseID parses an ID from hex characters, like the string returned by Id.
+// It is not case-sensitive.
+func ParseID(s string) (ID, error) {
+	n, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid query ID %q", s)
+	}
+	return ID(n), nil
+}
+
+// MarshalText returns ID.String.
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText parses the ID with ParseID.
+func (id *ID) UnmarshalText(text []byte) error {
+	n, err := ParseID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = n
+	return nil
+}
+
+// Scan implements the sql.Scanner interface for BIGINT columns. src can be an
+// int64, a uint64 (BIGINT UNSIGNED), or a decimal integer as []byte or string.
+func (id *ID) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		*id = ID(v)
+	case uint64:
+		*id = ID(v)
+	case []byte:
+		return id.scanString(string(v))
+	case string:
+		return id.scanString(v)
+	default:
+		return fmt.Errorf("cannot scan %T into query.ID", src)
+	}
+	return nil
+}
+
+func (id *ID) scanString(s string) error {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*id = ID(n)
+		return nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into query.ID", s)
+	}
+	*id = ID(n)
+	return nil
+}
+
+// Value implements the driver.Valuer interface. It returns the ID as int64.
+func (id ID) Value() (driver.Value, error) {
+	return int64(id), nil
+}
@@ -0,0 +1,149 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestIdHash(t *testing.T) {
+	f := "hello world"
+	expect := map[query.IdHash]string{
+		query.IdHashMD5:    "93CB22BB8F5ACDC3",
+		query.IdHashFNV1a:  "779A65E7023CD2E7",
+		query.IdHashSHA256: "B94D27B9934D3E08",
+		query.IdHashSpooky: "CE4E98819BFF125D",
+	}
+	for h, id := range expect {
+		if got := h.ID(f).String(); got != id {
+			t.Errorf("%s: got %s, expected %s", h, got, id)
+		}
+		fp := &query.Fingerprinter{IdHash: h}
+		if got := fp.ID(f).String(); got != id {
+			t.Errorf("Fingerprinter %s: got %s, expected %s", h, got, id)
+		}
+	}
+
+	// The default is compatible with Id.
+	f = "select sleep(?) from n"
+	if got := (&query.Fingerprinter{}).ID(f).String(); got != query.Id(f) {
+		t.Errorf("got %s, expected %s", got, query.Id(f))
+	}
+}
+
+func TestIdHashSpooky(t *testing.T) {
+	// SpookyHash V2 test vectors: Hash32 (the low 32 bits) of bytes 128, 129,
+	// ... of length n, from Bob Jenkins' TestResults. Lengths 32 and more use
+	// the 32-byte loop of the short hash, and 192 and more the long hash.
+	expect := []uint32{
+		0x6bf50919, 0x70de1d26, 0xa2b37298, 0x35bc5fbf, 0x8223b279, 0x5bcb315e,
+		0x53fe88a1, 0xf9f1a233, 0xee193982, 0x54f86f29, 0xc8772d36, 0x9ed60886,
+		0x5f23d1da, 0x1ed9f474, 0xf2ef0c89, 0x83ec01f9, 0xf274736c, 0x7e9ac0df,
+		0xc7aed250, 0xb1015811, 0xe23470f5, 0x48ac20c4, 0xe2ab3cd5, 0x608f8363,
+		0xd0639e68, 0xc4e8e7ab, 0x863c7c5b, 0x4ea63579, 0x99ae8622, 0x170c658b,
+		0x149ba493, 0x027bca7c, 0xe5cfc8b6, 0xce01d9d7, 0x11103330, 0x5d1f5ed4,
+		0xca720ecb, 0xef408aec, 0x733b90ec, 0x855737a6, 0x9856c65f, 0x647411f7,
+		0x50777c74, 0xf0f1a8b7, 0x9d7e55a5, 0xc68dd371, 0xfc1af2cc, 0x75728d0a,
+	}
+	long := map[int]uint32{
+		192: 0x77e012bd,
+		193: 0x2d05114c,
+		194: 0xaecf2ddd,
+		195: 0xb2a2b4aa,
+		287: 0xc22c642d,
+		288: 0x47880140,
+		289: 0xfbff3bec,
+		383: 0x4827f45c,
+		384: 0x44eb5634,
+		511: 0xcc1c8250,
+	}
+	buf := make([]byte, 512)
+	for i := range buf {
+		buf[i] = byte(i + 128)
+	}
+	for n, sum := range expect {
+		if got := uint32(query.IdHashSpooky.ID(string(buf[:n]))); got != sum {
+			t.Errorf("length %d: got %08x, expected %08x", n, got, sum)
+		}
+	}
+	for n, sum := range long {
+		if got := uint32(query.IdHashSpooky.ID(string(buf[:n]))); got != sum {
+			t.Errorf("length %d: got %08x, expected %08x", n, got, sum)
+		}
+	}
+
+	// Hash64 of strings, generated from the reference C++ code
+	expect64 := map[string]query.ID{
+		"":    0x232706fc6bf50919,
+		"abc": 0x8aab15f77537c967,
+		"Discard medicine more than two years old.": 0x9a2a8b03f065d989,
+		"The fugacity of a constituent in a mixture of gases at a given temperature is proportional to its mole fraction.  Lewis-Randall Rule": 0x995fd7a42818a4d4,
+	}
+	for s, sum := range expect64 {
+		if got := query.IdHashSpooky.ID(s); got != sum {
+			t.Errorf("%q: got %s, expected %s", s, got, sum)
+		}
+	}
+}
+
+func TestParseID(t *testing.T) {
+	id, err := query.ParseID("93cb22bb8f5acdc3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != query.ID(0x93CB22BB8F5ACDC3) {
+		t.Errorf("got %s, expected 93CB22BB8F5ACDC3", id)
+	}
+	if _, err := query.ParseID("not hex"); err == nil {
+		t.Error("no error parsing invalid ID")
+	}
+}
+
+func TestIDText(t *testing.T) {
+	m := map[string]query.ID{"id": 0x93CB22BB8F5ACDC3}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"id":"93CB22BB8F5ACDC3"}` {
+		t.Errorf("got %s", b)
+	}
+	m2 := map[string]query.ID{}
+	if err := json.Unmarshal(b, &m2); err != nil {
+		t.Fatal(err)
+	}
+	if m2["id"] != m["id"] {
+		t.Errorf("got %s, expected %s", m2["id"], m["id"])
+	}
+}
+
+func TestIDSQL(t *testing.T) {
+	id := query.ID(0x93CB22BB8F5ACDC3) // > math.MaxInt64
+	v, err := id.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, ok := v.(int64)
+	if !ok || n >= 0 {
+		t.Fatalf("got %T %v, expected negative int64", v, v)
+	}
+
+	for _, src := range []interface{}{n, uint64(id), []byte("-7797100140902560317"), "10649643932806991299"} {
+		var got query.ID
+		if err := got.Scan(src); err != nil {
+			t.Errorf("Scan(%T %v): %s", src, src, err)
+		} else if got != id {
+			t.Errorf("Scan(%T %v): got %s, expected %s", src, src, got, id)
+		}
+	}
+
+	var got query.ID
+	if err := got.Scan(1.5); err == nil {
+		t.Error("no error scanning float64")
+	}
+}
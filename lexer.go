@@ -0,0 +1,364 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"unicode/utf8"
+)
+
+// A TokenType is the type of a Token.
+type TokenType byte
+
+const (
+	TokenSpace        TokenType = iota // space, tab, \r, \n
+	TokenWord                          // keyword or unquoted identifier: SELECT, t1, db23
+	TokenNumber                        // 123, 1.5, .5, 1e-9
+	TokenHex                           // 0xFF or x'FF'
+	TokenBit                           // 0b01 or b'01'
+	TokenString                        // 'foo' or "foo"
+	TokenIdent                         // `foo`
+	TokenOperator                      // = <=> ( ) , ; . and all other punctuation
+	TokenComment                       // /* comment */, -- comment, # comment
+	TokenMySQLCode                     // /*!, /*!NNNNN, or /*M!NNNNN that begins MySQL-specific code
+	TokenMySQLCodeEnd                  // */ that ends MySQL-specific code
+	TokenHint                          // /*+ optimizer hints */
+	TokenParam                         // $1 (PostgreSQL)
+)
+
+var tokenTypeName = map[TokenType]string{
+	TokenSpace:        "space",
+	TokenWord:         "word",
+	TokenNumber:       "number",
+	TokenHex:          "hex",
+	TokenBit:          "bit",
+	TokenString:       "string",
+	TokenIdent:        "ident",
+	TokenOperator:     "operator",
+	TokenComment:      "comment",
+	TokenMySQLCode:    "mysql-code",
+	TokenMySQLCodeEnd: "mysql-code-end",
+	TokenHint:         "hint",
+	TokenParam:        "param",
+}
+
+func (t TokenType) String() string {
+	return tokenTypeName[t]
+}
+
+// A Token is one piece of a query returned by a Lexer. Text is the token
+// exactly as it appears in the query, and Start and End are its byte offsets
+// in the query: q[Start:End] == Text.
+type Token struct {
+	Type  TokenType
+	Text  string
+	Start int
+	End   int
+}
+
+// A Lexer splits a query into the same pieces that Fingerprint classifies as
+// it runs: words, numbers, quoted values, backtick-quoted identifiers,
+// operators, comments, /*+ optimizer hints */, and /*! MySQL-specific code */.
+// It follows the same rules, for example: 12ff is a number but 12ffz is a
+// word, a one-line comment begins with -- only if followed by a space, and
+// the \ escape char is honored in quoted values. Unlike Fingerprint, it does
+// not change anything: the Text of every token is the exact slice of the
+// query, so concatenating the Text of all tokens returns the original query.
+//
+// A Lexer returns the tokens of a query one at a time:
+//
+//	l := query.NewLexer(q)
+//	for l.Next() {
+//	    t := l.Token()
+//	}
+//
+// Input is never invalid: an unterminated quoted value or comment runs to the
+// end of the query.
+type Lexer struct {
+	// SQLMode changes how quoted values are scanned, like the server
+	// sql_mode. It must be set before the first call to Next.
+	SQLMode SQLMode
+
+	// Dialect is the SQL dialect of the query. It must be set before the
+	// first call to Next. See DialectPostgreSQL for the differences.
+	Dialect Dialect
+
+	q            string
+	pos          int // offset of next token
+	tok          Token
+	inCode       bool // in /*! MySQL-specific code */
+	unterminated bool // last token is an unterminated quoted value or comment
+}
+
+// NewLexer returns a Lexer for q.
+func NewLexer(q string) *Lexer {
+	return &Lexer{q: q}
+}
+
+// newLexer returns a Lexer for q with the Dialect and SQLMode of fp.
+func (fp *Fingerprinter) newLexer(q string) *Lexer {
+	l := NewLexer(q)
+	l.Dialect = fp.Dialect
+	l.SQLMode = fp.SQLMode
+	return l
+}
+
+// Tokenize returns all tokens in q, including space and comments.
+func Tokenize(q string) []Token {
+	tokens := []Token{}
+	l := NewLexer(q)
+	for l.Next() {
+		tokens = append(tokens, l.Token())
+	}
+	return tokens
+}
+
+// Token returns the current token, the one found by the last call to Next.
+func (l *Lexer) Token() Token {
+	return l.tok
+}
+
+// Next advances to the next token, which is then available through Token.
+// It returns false at the end of the query.
+func (l *Lexer) Next() bool {
+	if l.pos >= len(l.q) {
+		return false
+	}
+	start := l.pos
+	t := l.scan()
+	l.tok = Token{
+		Type:  t,
+		Text:  l.q[start:l.pos],
+		Start: start,
+		End:   l.pos,
+	}
+	return true
+}
+
+// scan scans the token at l.pos, advances l.pos to the end of the token, and
+// returns the token type.
+func (l *Lexer) scan() TokenType {
+	q := l.q
+	r, w := utf8.DecodeRuneInString(q[l.pos:])
+	next := l.peek(w)
+
+	if l.Dialect == DialectPostgreSQL {
+		if t, ok := l.scanPostgres(r, next); ok {
+			return t
+		}
+	}
+
+	switch {
+	case isSpace(r):
+		for l.pos < len(q) && isSpace(rune(q[l.pos])) {
+			l.pos++
+		}
+		return TokenSpace
+	case r == '`' || (r == '"' && l.SQLMode&ANSIQuotes != 0):
+		l.pos++
+		l.skipQuoted(byte(r), l.backslashEscapes(byte(r)))
+		return TokenIdent
+	case r == '\'' || r == '"':
+		l.pos++
+		l.skipQuoted(byte(r), l.backslashEscapes(byte(r)))
+		return TokenString
+	case (r == 'x' || r == 'X') && next == '\'':
+		l.pos += 2
+		l.skipQuoted('\'', l.backslashEscapes('\''))
+		return TokenHex
+	case (r == 'b' || r == 'B') && next == '\'':
+		l.pos += 2
+		l.skipQuoted('\'', l.backslashEscapes('\''))
+		return TokenBit
+	case r >= '0' && r <= '9':
+		return l.scanNumber()
+	case r == '.' && next >= '0' && next <= '9' && !l.afterIdent():
+		return l.scanNumber()
+	case isWordChar(r):
+		l.skipWord()
+		return TokenWord
+	case r == '/' && next == '*':
+		if l.peek(2) == '!' || (l.peek(2) == 'M' && l.peek(3) == '!') {
+			// /*![version] MySQL-specific code */ or
+			// /*M![version] MariaDB-specific code */
+			l.pos += 3
+			if q[l.pos-1] == 'M' {
+				l.pos++
+			}
+			for l.pos < len(q) && q[l.pos] >= '0' && q[l.pos] <= '9' {
+				l.pos++
+			}
+			l.inCode = true
+			return TokenMySQLCode
+		}
+		hint := l.peek(2) == '+'
+		l.pos += 2
+		for l.pos < len(q) && !(q[l.pos] == '*' && l.peek(1) == '/') {
+			l.pos++
+		}
+		l.pos += 2
+		if l.pos > len(q) {
+			l.pos = len(q)
+			l.unterminated = true
+		}
+		if hint {
+			return TokenHint
+		}
+		return TokenComment
+	case r == '*' && next == '/' && l.inCode:
+		l.pos += 2
+		l.inCode = false
+		return TokenMySQLCodeEnd
+	case r == '#' || (r == '-' && next == '-' && (isSpace(l.peek(2)) || l.pos+2 == len(q))):
+		// # comment or -- comment (at least one space after dash is required)
+		for l.pos < len(q) && q[l.pos] != '\n' {
+			l.pos++
+		}
+		return TokenComment
+	}
+
+	// Operators and all other punctuation
+	l.pos += w
+	if len(q)-l.pos >= 2 && (r == '<' && q[l.pos:l.pos+2] == "=>" || r == '-' && q[l.pos:l.pos+2] == ">>") {
+		l.pos += 2 // <=> or ->>
+		return TokenOperator
+	}
+	switch string(r) + string(next) {
+	case "<=", ">=", "<>", "!=", ":=", "<<", ">>", "&&", "||", "->":
+		l.pos++
+	}
+	return TokenOperator
+}
+
+// scanNumber scans a number literal, or a word that begins with digits like
+// 123foo. The rules are the same as Fingerprint: a number is a digit followed
+// by digits, hex digits, ., x, and -, so 12ff, 0x1F, 1e-9, and even 1-2 are
+// numbers, but it is a word if a letter after f or _ follows, like 12ffz.
+func (l *Lexer) scanNumber() TokenType {
+	q := l.q
+	start := l.pos
+	for l.pos < len(q) && (isHexDigit(q[l.pos]) || q[l.pos] == '.' || q[l.pos] == 'x' || q[l.pos] == '-') {
+		l.pos++
+	}
+	if l.pos < len(q) {
+		if c := q[l.pos]; (c >= 'g' && c <= 'z') || (c >= 'G' && c <= 'Z') || c == '_' {
+			// 123foo, 12ffz, 0xfg: a word that begins with digits
+			l.pos = start
+			l.skipWord()
+			return TokenWord
+		}
+	}
+	switch numberKind(q[start:l.pos]) {
+	case ValueHex:
+		return TokenHex
+	case ValueBit:
+		return TokenBit
+	}
+	return TokenNumber
+}
+
+// skipQuoted advances past the first unescaped quote char. l.pos must be
+// just after the opening quote char. A quote char is escaped by doubling it,
+// or by \ if escapes is true:
+//
+//	'It''s'
+//	'It\'s'
+func (l *Lexer) skipQuoted(quoteChar byte, escapes bool) {
+	q := l.q
+	for l.pos < len(q) {
+		c := q[l.pos]
+		l.pos++
+		if c == '\\' && escapes {
+			l.pos++ // skip escaped char
+		} else if c == quoteChar {
+			if l.pos < len(q) && q[l.pos] == quoteChar {
+				l.pos++ // doubled quote char
+				continue
+			}
+			return
+		}
+	}
+	l.pos = len(q)
+	l.unterminated = true
+}
+
+// backslashEscapes returns true if \ escapes chars in a value or identifier
+// quoted by quoteChar. Backslash does not escape in identifiers or with
+// NoBackslashEscapes.
+func (l *Lexer) backslashEscapes(quoteChar byte) bool {
+	return l.SQLMode&NoBackslashEscapes == 0 && quoteChar != '`' && (quoteChar != '"' || l.SQLMode&ANSIQuotes == 0)
+}
+
+func (l *Lexer) skipWord() {
+	for l.pos < len(l.q) {
+		r, w := utf8.DecodeRuneInString(l.q[l.pos:])
+		if !isWordChar(r) {
+			return
+		}
+		l.pos += w
+	}
+}
+
+func (l *Lexer) skipDigits() {
+	for l.pos < len(l.q) && l.q[l.pos] >= '0' && l.q[l.pos] <= '9' {
+		l.pos++
+	}
+}
+
+// peek returns the byte n bytes after l.pos, or 0 if past the end.
+func (l *Lexer) peek(n int) rune {
+	if l.pos+n < len(l.q) {
+		return rune(l.q[l.pos+n])
+	}
+	return 0
+}
+
+// afterIdent returns true if l.pos immediately follows a word or identifier,
+// like the dot in t.c.
+func (l *Lexer) afterIdent() bool {
+	if l.tok.End != l.pos || l.pos == 0 {
+		return false
+	}
+	return l.tok.Type == TokenWord || l.tok.Type == TokenIdent
+}
+
+// isWordChar returns true for chars of unquoted identifiers and keywords.
+// MySQL allows any non-ASCII char in unquoted identifiers.
+func isWordChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '$' || r >= 0x80
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// syntaxError returns a *SyntaxError if the last token is an unterminated
+// quoted value or comment, else nil.
+func (l *Lexer) syntaxError() *SyntaxError {
+	if !l.unterminated {
+		return nil
+	}
+	msg := "unterminated comment"
+	switch l.tok.Type {
+	case TokenString, TokenHex, TokenBit:
+		msg = "unterminated quoted value"
+	case TokenIdent:
+		msg = "unterminated quoted identifier"
+	}
+	return &SyntaxError{Offset: l.tok.Start, State: l.tok.Type.String(), Msg: msg}
+}
+
+// nextCode is like Next but it skips space, comments, hints, and the /*! and
+// */ that begin and end MySQL-specific code, so only tokens that the server
+// executes are returned.
+func (l *Lexer) nextCode() bool {
+	for l.Next() {
+		switch l.tok.Type {
+		case TokenSpace, TokenComment, TokenHint, TokenMySQLCode, TokenMySQLCodeEnd:
+			continue
+		}
+		return true
+	}
+	return false
+}
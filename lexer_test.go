@@ -0,0 +1,203 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+// tokenString returns the non-space tokens as "type:text" for easy comparison.
+func tokenString(tokens []query.Token) string {
+	s := []string{}
+	for _, t := range tokens {
+		if t.Type == query.TokenSpace {
+			continue
+		}
+		s = append(s, t.Type.String()+":"+t.Text)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestTokenize(t *testing.T) {
+	var q string
+	var expect string
+
+	q = "SELECT c FROM t WHERE id=1"
+	expect = "word:SELECT word:c word:FROM word:t word:WHERE word:id operator:= number:1"
+	if got := tokenString(query.Tokenize(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+
+	// Numbers, hex, and bit values
+	q = "select 0e0, 6e-30, 5001., .5, 0x0F, x'0F', 0b01, b'01' from foo"
+	expect = "word:select number:0e0 operator:, number:6e-30 operator:, number:5001. operator:, number:.5 operator:, hex:0x0F operator:, hex:x'0F' operator:, bit:0b01 operator:, bit:b'01' word:from word:foo"
+	if got := tokenString(query.Tokenize(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+
+	// Like Fingerprint, 12ff is a number, but 123foo is a word
+	q = "select 123foo, 12ff, t1.c from db23.t"
+	expect = "word:select word:123foo operator:, number:12ff operator:, word:t1 operator:. word:c word:from word:db23 operator:. word:t"
+	if got := tokenString(query.Tokenize(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+
+	// Quoted values and identifiers
+	q = "select 'It\\'s', 'It''s', \"a ) b\", `table-1` from t"
+	expect = "word:select string:'It\\'s' operator:, string:'It''s' operator:, string:\"a ) b\" operator:, ident:`table-1` word:from word:t"
+	if got := tokenString(query.Tokenize(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+
+	// Comments and MySQL-specific code
+	q = "/* c1 */ SELECT /*!40001 SQL_NO_CACHE */ * FROM t -- c2\n# c3\nWHERE a--b"
+	expect = "comment:/* c1 */ word:SELECT mysql-code:/*!40001 word:SQL_NO_CACHE mysql-code-end:*/ operator:* word:FROM word:t comment:-- c2 comment:# c3 word:WHERE word:a operator:- operator:- word:b"
+	if got := tokenString(query.Tokenize(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+
+	// MariaDB-specific code
+	q = "CREATE /*M! OR REPLACE */ TABLE t /*M!100100 WITH SYSTEM VERSIONING */"
+	expect = "word:CREATE mysql-code:/*M! word:OR word:REPLACE mysql-code-end:*/ word:TABLE word:t mysql-code:/*M!100100 word:WITH word:SYSTEM word:VERSIONING mysql-code-end:*/"
+	if got := tokenString(query.Tokenize(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+
+	// Optimizer hints
+	q = "SELECT /*+ BKA(t1) */ c FROM t1 /* c1 */"
+	expect = "word:SELECT hint:/*+ BKA(t1) */ word:c word:FROM word:t1 comment:/* c1 */"
+	if got := tokenString(query.Tokenize(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+
+	// Multi-char operators
+	q = "a<=>b<=c>=d<>e!=f:=g->>h"
+	expect = "word:a operator:<=> word:b operator:<= word:c operator:>= word:d operator:<> word:e operator:!= word:f operator::= word:g operator:->> word:h"
+	if got := tokenString(query.Tokenize(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+
+	// Unterminated quoted value and comment run to the end
+	q = "select 'foo"
+	expect = "word:select string:'foo"
+	if got := tokenString(query.Tokenize(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+	q = "select /* foo"
+	expect = "word:select comment:/* foo"
+	if got := tokenString(query.Tokenize(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+}
+
+func TestLexerSQLMode(t *testing.T) {
+	q := `SELECT "c" FROM t WHERE a='C:\' AND b="x"`
+	expect := map[query.SQLMode]string{
+		0:                        `word:SELECT string:"c" word:FROM word:t word:WHERE word:a operator:= string:'C:\' AND b="x"`,
+		query.ANSIQuotes:         `word:SELECT ident:"c" word:FROM word:t word:WHERE word:a operator:= string:'C:\' AND b="x"`,
+		query.NoBackslashEscapes: `word:SELECT string:"c" word:FROM word:t word:WHERE word:a operator:= string:'C:\' word:AND word:b operator:= string:"x"`,
+	}
+	for mode, s := range expect {
+		l := query.NewLexer(q)
+		l.SQLMode = mode
+		tokens := []query.Token{}
+		for l.Next() {
+			tokens = append(tokens, l.Token())
+		}
+		if got := tokenString(tokens); got != s {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s\n", mode, got, s)
+		}
+	}
+}
+
+func TestTokenizeOffsets(t *testing.T) {
+	q := "SELECT `c`, 'x'  /* y */\nFROM t WHERE id IN (1, 2.5) -- z"
+	text := ""
+	end := 0
+	for _, tok := range query.Tokenize(q) {
+		if tok.Start != end {
+			t.Errorf("token %+v starts at %d, expected %d", tok, tok.Start, end)
+		}
+		if q[tok.Start:tok.End] != tok.Text {
+			t.Errorf("token %+v does not match q[%d:%d]", tok, tok.Start, tok.End)
+		}
+		text += tok.Text
+		end = tok.End
+	}
+	if text != q {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", text, q)
+	}
+}
+
+func TestLexer(t *testing.T) {
+	l := query.NewLexer("select 1")
+	types := []query.TokenType{}
+	for l.Next() {
+		types = append(types, l.Token().Type)
+	}
+	expect := []query.TokenType{query.TokenWord, query.TokenSpace, query.TokenNumber}
+	if len(types) != len(expect) {
+		t.Fatalf("got %v, expected %v", types, expect)
+	}
+	for i := range expect {
+		if types[i] != expect[i] {
+			t.Errorf("token %d: got %s, expected %s", i, types[i], expect[i])
+		}
+	}
+	if l.Next() {
+		t.Error("Next returned true after end of query")
+	}
+}
+
+// TestLexerParity checks that the Lexer and Fingerprint agree on values in
+// fingerprintTests: each number, hex, bit, and quoted value that Parameterize
+// returns is one Lexer token (after a sign), and no value is part of a word.
+func TestLexerParity(t *testing.T) {
+	valueType := map[query.ValueKind][]query.TokenType{
+		query.ValueNumber: {query.TokenNumber},
+		query.ValueHex:    {query.TokenHex},
+		query.ValueBit:    {query.TokenBit},
+		query.ValueString: {query.TokenString, query.TokenIdent}, // "foo" with ANSIQuotes
+	}
+	for _, test := range fingerprintTests {
+		q := test.q
+		_, values := query.Parameterize(q)
+		tokens := query.Tokenize(q)
+		for _, v := range values {
+			types, ok := valueType[v.Kind]
+			if !ok {
+				continue // NULL or a list
+			}
+			start := v.Start
+			if v.Kind == query.ValueNumber && (q[start] == '-' || q[start] == '+') {
+				start++
+			}
+			found := false
+			for _, tok := range tokens {
+				if tok.Start == start && tok.End == v.End {
+					for _, typ := range types {
+						found = found || tok.Type == typ
+					}
+				}
+			}
+			if !found {
+				t.Errorf("%s: value %s at %d is not a %s token:\n%s", q, v.Text, v.Start, types[0], tokenString(tokens))
+			}
+		}
+		for _, tok := range tokens {
+			if tok.Type != query.TokenWord {
+				continue
+			}
+			for _, v := range values {
+				if v.Start < tok.End && v.End > tok.Start && v.Kind != query.ValueList && v.Kind != query.ValueNull {
+					t.Errorf("%s: value %s at %d is in word %s", q, v.Text, v.Start, tok.Text)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"strings"
+)
+
+// PerfSchemaDigestText returns the digest text of q like MySQL 8.0 writes in
+// the DIGEST_TEXT column of
+// performance_schema.events_statements_summary_by_digest. Unlike Fingerprint:
+//   - Tokens are separated by one space: SELECT `c` FROM `db` . `t`
+//   - Keywords are uppercase, including non-reserved keywords like STATUS
+//   - Identifiers, and functions that are not keywords, are backtick-quoted
+//   - Values are ?, and a negative value like -1 is one value
+//   - A list of values is "?, ...", (1) is "(?)", and (1, 2) is "(...)"
+//   - A list of rows is "(?) /* , ... */" or "(...) /* , ... */"
+//   - Comments are removed but /*! MySQL-specific code */ is kept
+//   - NULL is a value, except in IS [NOT] NULL
+//
+// The digest text matches MySQL, so it can be used to join query classes with
+// performance_schema rows on DIGEST_TEXT. The DIGEST column cannot be computed
+// because MySQL hashes its internal token numbers, which differ between
+// versions, not the text. Digest texts are not truncated to
+// performance_schema_max_digest_length.
+func PerfSchemaDigestText(q string) string {
+	d := &psDigest{}
+	l := NewLexer(q)
+	for l.nextCode() {
+		d.add(l.Token())
+	}
+	if n := len(d.toks); n > 0 && d.toks[n-1].kind == psText && d.toks[n-1].text == ";" {
+		d.toks = d.toks[:n-1]
+	}
+	s := make([]string, len(d.toks))
+	for i, t := range d.toks {
+		s[i] = t.String()
+	}
+	return strings.Join(s, " ")
+}
+
+// Kinds of psToken. Values are reduced like MySQL sql/sql_digest.cc.
+const (
+	psText          byte = iota // keyword, identifier, or operator
+	psValue                     // ?
+	psValueList                 // ?, ...
+	psRowSingle                 // (?)
+	psRowSingleList             // (?) /* , ... */
+	psRowMulti                  // (...)
+	psRowMultiList              // (...) /* , ... */
+)
+
+type psToken struct {
+	kind byte
+	text string // only if kind is psText
+}
+
+func (t psToken) String() string {
+	switch t.kind {
+	case psValue:
+		return "?"
+	case psValueList:
+		return "?, ..."
+	case psRowSingle:
+		return "(?)"
+	case psRowSingleList:
+		return "(?) /* , ... */"
+	case psRowMulti:
+		return "(...)"
+	case psRowMultiList:
+		return "(...) /* , ... */"
+	}
+	return t.text
+}
+
+type psDigest struct {
+	toks []psToken
+	code []Token // all tokens added, before reduction
+}
+
+func (d *psDigest) add(t Token) {
+	d.code = append(d.code, t)
+	switch t.Type {
+	case TokenNumber, TokenHex, TokenBit, TokenString:
+		d.addValue()
+		return
+	case TokenIdent:
+		d.push(psToken{kind: psText, text: t.Text})
+		return
+	case TokenWord:
+		word := strings.ToUpper(t.Text)
+		switch {
+		case word == "NULL" && !d.last(0, "IS") && !(d.last(0, "NOT") && d.last(1, "IS")):
+			d.addValue()
+		case mysqlKeywords[word]:
+			d.push(psToken{kind: psText, text: word})
+		case word[0] == '_' && mysqlCharsets[word[1:]]:
+			// _utf8mb4'abc': the charset introducer is part of the value
+		default:
+			d.push(psToken{kind: psText, text: "`" + strings.Replace(t.Text, "`", "``", -1) + "`"})
+		}
+		return
+	}
+
+	switch t.Text {
+	case "?":
+		d.addValue()
+	case ")":
+		d.addParen()
+	case "<>":
+		d.push(psToken{kind: psText, text: "!="})
+	default:
+		d.push(psToken{kind: psText, text: t.Text})
+	}
+}
+
+// addValue adds a value: [+-] value, value ',' value, and list ',' value are
+// reduced.
+func (d *psDigest) addValue() {
+	if n := len(d.toks); n > 0 && (d.last(0, "-") || d.last(0, "+")) && startsExpr(d.code, len(d.code)-3, mysqlKeywords) {
+		d.toks = d.toks[:n-1] // unary -1 or +1
+	}
+	if n := len(d.toks); n > 1 && d.last(0, ",") && (d.toks[n-2].kind == psValue || d.toks[n-2].kind == psValueList) {
+		d.toks = d.toks[:n-1]
+		d.toks[n-2].kind = psValueList
+		return
+	}
+	d.push(psToken{kind: psValue})
+}
+
+// addParen adds a closing parenthesis: '(' value ')' and '(' list ')' are
+// reduced to a row, and rows separated by commas to a row list.
+func (d *psDigest) addParen() {
+	n := len(d.toks)
+	if n < 2 || !d.last(1, "(") {
+		d.push(psToken{kind: psText, text: ")"})
+		return
+	}
+	var row, list byte
+	switch d.toks[n-1].kind {
+	case psValue:
+		row, list = psRowSingle, psRowSingleList
+	case psValueList:
+		row, list = psRowMulti, psRowMultiList
+	default:
+		d.push(psToken{kind: psText, text: ")"})
+		return
+	}
+	d.toks = d.toks[:n-2]
+	n -= 2
+	if n > 1 && d.last(0, ",") && (d.toks[n-2].kind == row || d.toks[n-2].kind == list) {
+		d.toks = d.toks[:n-1]
+		d.toks[n-2].kind = list
+		return
+	}
+	d.push(psToken{kind: row})
+}
+
+func (d *psDigest) push(t psToken) {
+	d.toks = append(d.toks, t)
+}
+
+// last returns true if the token n from the end is text.
+func (d *psDigest) last(n int, text string) bool {
+	i := len(d.toks) - 1 - n
+	return i >= 0 && d.toks[i].kind == psText && d.toks[i].text == text
+}
+
+// mysqlCharsets are the character sets that can be used as an introducer,
+// like _utf8mb4'abc'.
+var mysqlCharsets = map[string]bool{}
+
+// mysqlKeywords are the MySQL 8.0 keywords, reserved and non-reserved.
+var mysqlKeywords = map[string]bool{}
+
+func init() {
+	for _, cs := range strings.Fields(`armscii8 ascii big5 binary cp1250 cp1251
+		cp1256 cp1257 cp850 cp852 cp866 cp932 dec8 eucjpms euckr gb18030 gb2312
+		gbk geostd8 greek hebrew hp8 keybcs2 koi8r koi8u latin1 latin2 latin5
+		latin7 macce macroman sjis swe7 tis620 ucs2 ujis utf16 utf16le utf32
+		utf8 utf8mb3 utf8mb4`) {
+		mysqlCharsets[strings.ToUpper(cs)] = true
+	}
+	for _, kw := range strings.Fields(mysqlKeywordList) {
+		mysqlKeywords[kw] = true
+	}
+}
+
+const mysqlKeywordList = `
+ACCESSIBLE ACCOUNT ACTION ACTIVE ADD ADMIN AFTER AGAINST AGGREGATE ALGORITHM
+ALL ALTER ALWAYS ANALYZE AND ANY ARRAY AS ASC ASCII ASENSITIVE AT ATTRIBUTE
+AUTHENTICATION AUTOEXTEND_SIZE AUTO_INCREMENT AVG AVG_ROW_LENGTH BACKUP
+BEFORE BEGIN BETWEEN BIGINT BINARY BINLOG BIT BIT_AND BIT_OR BIT_XOR BLOB
+BLOCK BOOL BOOLEAN BOTH BTREE BUCKETS BY BYTE CACHE CALL CASCADE CASCADED
+CASE CAST CATALOG_NAME CHAIN CHANGE CHANGED CHANNEL CHAR CHARACTER CHARSET
+CHECK CHECKSUM CIPHER CLASS_ORIGIN CLIENT CLONE CLOSE COALESCE CODE COLLATE
+COLLATION COLUMN COLUMNS COLUMN_FORMAT COLUMN_NAME COMMENT COMMIT COMMITTED
+COMPACT COMPLETION COMPONENT COMPRESSED COMPRESSION CONCURRENT CONDITION
+CONNECTION CONSISTENT CONSTRAINT CONSTRAINT_CATALOG CONSTRAINT_NAME
+CONSTRAINT_SCHEMA CONTAINS CONTEXT CONTINUE CONVERT COUNT CPU CREATE CROSS
+CUBE CUME_DIST CURDATE CURRENT CURRENT_DATE CURRENT_TIME CURRENT_TIMESTAMP
+CURRENT_USER CURSOR CURSOR_NAME CURTIME DATA DATABASE DATABASES DATAFILE DATE
+DATETIME DATE_ADD DATE_SUB DAY DAY_HOUR DAY_MICROSECOND DAY_MINUTE DAY_SECOND
+DEALLOCATE DEC DECIMAL DECLARE DEFAULT DEFAULT_AUTH DEFINER DEFINITION
+DELAYED DELAY_KEY_WRITE DELETE DENSE_RANK DESC DESCRIBE DESCRIPTION
+DETERMINISTIC DIAGNOSTICS DIRECTORY DISABLE DISCARD DISK DISTINCT
+DISTINCTROW DIV DO DOUBLE DROP DUAL DUMPFILE DUPLICATE DYNAMIC EACH ELSE
+ELSEIF EMPTY ENABLE ENCLOSED ENCRYPTION END ENDS ENFORCED ENGINE ENGINES
+ENGINE_ATTRIBUTE ENUM ERROR ERRORS ESCAPE ESCAPED EVENT EVENTS EVERY EXCEPT
+EXCHANGE EXCLUDE EXECUTE EXISTS EXIT EXPANSION EXPIRE EXPLAIN EXPORT
+EXTENDED EXTENT_SIZE EXTRACT FAILED_LOGIN_ATTEMPTS FALSE FAST FAULTS FETCH
+FIELDS FILE FILE_BLOCK_SIZE FILTER FIRST FIRST_VALUE FIXED FLOAT FLOAT4
+FLOAT8 FLUSH FOLLOWING FOLLOWS FOR FORCE FOREIGN FORMAT FOUND FROM FULL
+FULLTEXT FUNCTION GENERAL GENERATED GEOMCOLLECTION GEOMETRY
+GEOMETRYCOLLECTION GET GET_FORMAT GET_MASTER_PUBLIC_KEY GLOBAL GRANT GRANTS
+GROUP GROUPING GROUPS GROUP_CONCAT GROUP_REPLICATION HANDLER HASH HAVING HELP
+HIGH_PRIORITY HISTOGRAM HISTORY HOST HOSTS HOUR HOUR_MICROSECOND HOUR_MINUTE
+HOUR_SECOND IDENTIFIED IF IGNORE IGNORE_SERVER_IDS IMPORT IN INACTIVE INDEX
+INDEXES INFILE INITIAL_SIZE INNER INOUT INSENSITIVE INSERT INSERT_METHOD
+INSTALL INSTANCE INT INT1 INT2 INT3 INT4 INT8 INTEGER INTERSECT INTERVAL INTO
+INVISIBLE INVOKER IO IO_AFTER_GTIDS IO_BEFORE_GTIDS IO_THREAD IPC IS
+ISOLATION ISSUER ITERATE JOIN JSON JSON_ARRAYAGG JSON_OBJECTAGG JSON_TABLE
+JSON_VALUE KEY KEYS KEY_BLOCK_SIZE KILL LAG LANGUAGE LAST LAST_VALUE LATERAL
+LEAD LEADING LEAVE LEAVES LEFT LESS LEVEL LIKE LIMIT LINEAR LINES LINESTRING
+LIST LOAD LOCAL LOCALTIME LOCALTIMESTAMP LOCK LOCKED LOCKS LOGFILE LOGS LONG
+LONGBLOB LONGTEXT LOOP LOW_PRIORITY MASTER MASTER_AUTO_POSITION MASTER_BIND
+MASTER_COMPRESSION_ALGORITHMS MASTER_CONNECT_RETRY MASTER_DELAY
+MASTER_HEARTBEAT_PERIOD MASTER_HOST MASTER_LOG_FILE MASTER_LOG_POS
+MASTER_PASSWORD MASTER_PORT MASTER_PUBLIC_KEY_PATH MASTER_RETRY_COUNT
+MASTER_SSL MASTER_SSL_CA MASTER_SSL_CAPATH MASTER_SSL_CERT MASTER_SSL_CIPHER
+MASTER_SSL_CRL MASTER_SSL_CRLPATH MASTER_SSL_KEY MASTER_SSL_VERIFY_SERVER_CERT
+MASTER_TLS_CIPHERSUITES MASTER_TLS_VERSION MASTER_USER
+MASTER_ZSTD_COMPRESSION_LEVEL MATCH MAX MAXVALUE MAX_CONNECTIONS_PER_HOUR
+MAX_QUERIES_PER_HOUR MAX_ROWS MAX_SIZE MAX_UPDATES_PER_HOUR
+MAX_USER_CONNECTIONS MEDIUM MEDIUMBLOB MEDIUMINT MEDIUMTEXT MEMBER MEMORY
+MERGE MESSAGE_TEXT MICROSECOND MID MIDDLEINT MIGRATE MIN MINUTE
+MINUTE_MICROSECOND MINUTE_SECOND MIN_ROWS MOD MODE MODIFIES MODIFY MONTH
+MULTILINESTRING MULTIPOINT MULTIPOLYGON MUTEX MYSQL_ERRNO NAME NAMES NATIONAL
+NATURAL NCHAR NDB NDBCLUSTER NESTED NETWORK_NAMESPACE NEVER NEW NEXT NO
+NODEGROUP NONE NOT NOW NOWAIT NO_WAIT NO_WRITE_TO_BINLOG NTH_VALUE NTILE NULL
+NULLS NUMBER NUMERIC NVARCHAR OF OFF OFFSET OJ OLD ON ONE ONLY OPEN OPTIMIZE
+OPTIMIZER_COSTS OPTION OPTIONAL OPTIONALLY OPTIONS OR ORDER ORDINALITY
+ORGANIZATION OTHERS OUT OUTER OUTFILE OVER OWNER PACK_KEYS PAGE PARSER
+PARTIAL PARTITION PARTITIONING PARTITIONS PASSWORD PASSWORD_LOCK_TIME PATH
+PERCENT_RANK PERSIST PERSIST_ONLY PHASE PLUGIN PLUGINS PLUGIN_DIR POINT
+POLYGON PORT POSITION PRECEDES PRECEDING PRECISION PREPARE PRESERVE PREV
+PRIMARY PRIVILEGES PRIVILEGE_CHECKS_USER PROCEDURE PROCESS PROCESSLIST
+PROFILE PROFILES PROXY PURGE QUARTER QUERY QUICK RANDOM RANGE RANK READ READS
+READ_ONLY READ_WRITE REAL REBUILD RECOVER RECURSIVE REDO_BUFFER_SIZE
+REDUNDANT REFERENCE REFERENCES REGEXP RELAY RELAYLOG RELAY_LOG_FILE
+RELAY_LOG_POS RELAY_THREAD RELEASE RELOAD REMOVE RENAME REORGANIZE REPAIR
+REPEAT REPEATABLE REPLACE REPLICATE_DO_DB REPLICATE_DO_TABLE
+REPLICATE_IGNORE_DB REPLICATE_IGNORE_TABLE REPLICATE_REWRITE_DB
+REPLICATE_WILD_DO_TABLE REPLICATE_WILD_IGNORE_TABLE REPLICATION REQUIRE
+REQUIRE_ROW_FORMAT RESET RESIGNAL RESOURCE RESPECT RESTART RESTORE RESTRICT
+RESUME RETAIN RETURN RETURNED_SQLSTATE RETURNING RETURNS REUSE REVERSE
+REVOKE RIGHT RLIKE ROLE ROLLBACK ROLLUP ROTATE ROUTINE ROW ROWS ROW_COUNT
+ROW_FORMAT ROW_NUMBER RTREE SAVEPOINT SCHEDULE SCHEMA SCHEMAS SCHEMA_NAME
+SECOND SECONDARY SECONDARY_ENGINE SECONDARY_ENGINE_ATTRIBUTE SECONDARY_LOAD
+SECONDARY_UNLOAD SECOND_MICROSECOND SECURITY SELECT SENSITIVE SEPARATOR
+SERIAL SERIALIZABLE SERVER SESSION SESSION_USER SET SHARE SHOW SHUTDOWN
+SIGNAL SIGNED SIMPLE SKIP SLAVE SLOW SMALLINT SNAPSHOT SOCKET SOME SONAME
+SOUNDS SOURCE SPATIAL SPECIFIC SQL SQLEXCEPTION SQLSTATE SQLWARNING
+SQL_AFTER_GTIDS SQL_AFTER_MTS_GAPS SQL_BEFORE_GTIDS SQL_BIG_RESULT
+SQL_BUFFER_RESULT SQL_CALC_FOUND_ROWS SQL_NO_CACHE SQL_SMALL_RESULT
+SQL_THREAD SQL_TSI_DAY SQL_TSI_HOUR SQL_TSI_MINUTE SQL_TSI_MONTH
+SQL_TSI_QUARTER SQL_TSI_SECOND SQL_TSI_WEEK SQL_TSI_YEAR SRID SSL STACKED
+START STARTING STARTS STATS_AUTO_RECALC STATS_PERSISTENT STATS_SAMPLE_PAGES
+STATUS STD STDDEV STDDEV_POP STDDEV_SAMP STOP STORAGE STORED STRAIGHT_JOIN
+STREAM STRING SUBCLASS_ORIGIN SUBDATE SUBJECT SUBPARTITION SUBPARTITIONS
+SUBSTR SUBSTRING SUM SUPER SUSPEND SWAPS SWITCHES SYSDATE SYSTEM SYSTEM_USER
+TABLE TABLES TABLESPACE TABLE_CHECKSUM TABLE_NAME TEMPORARY TEMPTABLE
+TERMINATED TEXT THAN THEN THREAD_PRIORITY TIES TIME TIMESTAMP TIMESTAMPADD
+TIMESTAMPDIFF TINYBLOB TINYINT TINYTEXT TLS TO TRAILING TRANSACTION TRIGGER
+TRIGGERS TRIM TRUE TRUNCATE TYPE TYPES UNBOUNDED UNCOMMITTED UNDEFINED UNDO
+UNDOFILE UNDO_BUFFER_SIZE UNICODE UNINSTALL UNION UNIQUE UNKNOWN UNLOCK
+UNSIGNED UNTIL UPDATE UPGRADE USAGE USE USER USER_RESOURCES USE_FRM USING
+UTC_DATE UTC_TIME UTC_TIMESTAMP VALIDATION VALUE VALUES VARBINARY VARCHAR
+VARCHARACTER VARIABLES VARIANCE VARYING VAR_POP VAR_SAMP VCPU VIEW VIRTUAL
+VISIBLE WAIT WARNINGS WEEK WEIGHT_STRING WHEN WHERE WHILE WINDOW WITH
+WITHOUT WORK WRAPPER WRITE X509 XA XID XML XOR YEAR YEAR_MONTH ZEROFILL
+`
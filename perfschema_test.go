@@ -0,0 +1,60 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestPerfSchemaDigestText(t *testing.T) {
+	tests := []struct {
+		q    string
+		text string
+	}{
+		{
+			"SELECT * FROM orders WHERE customer_id=10 AND quantity>20",
+			"SELECT * FROM `orders` WHERE `customer_id` = ? AND `quantity` > ?",
+		},
+		{
+			"select c from db.`t` where id in (1, 2, 3)",
+			"SELECT `c` FROM `db` . `t` WHERE `id` IN (...)",
+		},
+		{
+			"INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y'), (3, 'z')",
+			"INSERT INTO `t` ( `a` , `b` ) VALUES (...) /* , ... */",
+		},
+		{
+			"insert into t values (1), (-2)",
+			"INSERT INTO `t` VALUES (?) /* , ... */",
+		},
+		{
+			"SELECT sleep(1)",
+			"SELECT `sleep` (?)",
+		},
+		{
+			"SELECT COUNT(*) FROM t WHERE a = -1 AND b - 1 > 0 LIMIT 10, 20",
+			"SELECT COUNT ( * ) FROM `t` WHERE `a` = ? AND `b` - ? > ? LIMIT ?, ...",
+		},
+		{
+			"select a, _utf8mb4'x' from t where b is null and c is not null and d <> null -- foo\n;",
+			"SELECT `a` , ? FROM `t` WHERE `b` IS NULL AND `c` IS NOT NULL AND `d` != ?",
+		},
+		{
+			"/*!40101 SET NAMES utf8 */",
+			"SET NAMES `utf8`",
+		},
+		{
+			"SELECT c FROM t WHERE id = ? AND ts > NOW() - INTERVAL 1 DAY",
+			"SELECT `c` FROM `t` WHERE `id` = ? AND `ts` > NOW ( ) - INTERVAL ? DAY",
+		},
+	}
+	for _, test := range tests {
+		if text := query.PerfSchemaDigestText(test.q); text != test.text {
+			t.Errorf("got:\n%s\nexpected:\n%s\n", text, test.text)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A Dialect is the SQL dialect of queries.
+type Dialect int
+
+const (
+	// DialectMySQL is MySQL, the default.
+	DialectMySQL Dialect = iota
+
+	// DialectPostgreSQL is PostgreSQL. Compared to MySQL:
+	//   - "foo" is an identifier, and U&"foo" too
+	//   - 'foo' has no \ escapes, but E'foo' does, and U&'foo' is a string
+	//   - $tag$foo$tag$ and $$foo$$ are dollar-quoted strings
+	//   - $1 is a parameter
+	//   - :: is the cast operator, like '1'::int
+	//   - /* comments /* nest */ */ and -- does not need a space after it
+	//   - # and ` are operators, and there is no /*! MySQL-specific code */
+	DialectPostgreSQL
+)
+
+var dialectName = map[Dialect]string{
+	DialectMySQL:      "mysql",
+	DialectPostgreSQL: "postgresql",
+}
+
+func (d Dialect) String() string {
+	if s, ok := dialectName[d]; ok {
+		return s
+	}
+	return "Dialect(" + strconv.Itoa(int(d)) + ")"
+}
+
+// scanPostgres scans the PostgreSQL tokens that MySQL does not have or that
+// it scans differently. It returns false for the other tokens.
+func (l *Lexer) scanPostgres(r, next rune) (TokenType, bool) {
+	q := l.q
+	switch {
+	case r == '"':
+		l.pos++
+		l.skipQuoted('"', false)
+		return TokenIdent, true
+	case r == '\'':
+		l.pos++
+		l.skipQuoted('\'', false) // standard_conforming_strings
+		return TokenString, true
+	case (r == 'e' || r == 'E') && next == '\'':
+		l.pos += 2
+		l.skipQuoted('\'', true)
+		return TokenString, true
+	case (r == 'u' || r == 'U') && next == '&' && (l.peek(2) == '\'' || l.peek(2) == '"'):
+		quote := byte(l.peek(2))
+		l.pos += 3
+		l.skipQuoted(quote, false)
+		if quote == '"' {
+			return TokenIdent, true
+		}
+		return TokenString, true
+	case r == '$' && next >= '0' && next <= '9':
+		l.pos++
+		l.skipDigits()
+		return TokenParam, true
+	case r == '$':
+		// $$dollar-quoted string$$ or $tag$dollar-quoted string$tag$
+		end := l.pos + 1
+		for end < len(q) && q[end] != '$' && isWordChar(rune(q[end])) {
+			end++
+		}
+		if end >= len(q) || q[end] != '$' {
+			return 0, false
+		}
+		tag := q[l.pos : end+1]
+		if i := strings.Index(q[end+1:], tag); i >= 0 {
+			l.pos = end + 1 + i + len(tag)
+		} else {
+			l.pos = len(q)
+			l.unterminated = true
+		}
+		return TokenString, true
+	case r == '/' && next == '*':
+		// /* comments /* nest */ */
+		hint := l.peek(2) == '+'
+		depth := 0
+		for l.pos < len(q) {
+			if q[l.pos] == '/' && l.peek(1) == '*' {
+				depth++
+				l.pos += 2
+			} else if q[l.pos] == '*' && l.peek(1) == '/' {
+				depth--
+				l.pos += 2
+				if depth == 0 {
+					break
+				}
+			} else {
+				l.pos++
+			}
+		}
+		if depth > 0 {
+			l.unterminated = true
+		}
+		if hint {
+			return TokenHint, true
+		}
+		return TokenComment, true
+	case r == '-' && next == '-':
+		for l.pos < len(q) && q[l.pos] != '\n' {
+			l.pos++
+		}
+		return TokenComment, true
+	case r == '#' || r == '`':
+		l.pos++
+		return TokenOperator, true
+	case r == ':' && next == ':':
+		l.pos += 2
+		return TokenOperator, true
+	}
+	return 0, false
+}
+
+// pgFingerprint fingerprints PostgreSQL queries with the same transformations
+// as the MySQL state machine, but from tokens.
+type pgFingerprint struct {
+	q      string
+	toks   []Token // tokens that the fingerprint copies or replaces
+	spaces []bool  // parallel to toks: space or comment before the token
+	f      []byte
+	values []Value
+	space  bool // write a space before the next token
+	err    *SyntaxError
+}
+
+// fingerprintPostgres fingerprints q in the PostgreSQL dialect. It has the
+// same transformations as the MySQL fingerprint, plus:
+//
+//	$1                 -> ?
+//	ARRAY[1, 2, 3]     -> array[?+]
+//	'{1,2}'::int[]     -> ?::int[]
+//	$$foo$$, E'foo\n'  -> ?
+func (fp *Fingerprinter) fingerprintPostgres(q string, params bool) *pgFingerprint {
+	p := &pgFingerprint{
+		q: q,
+		f: make([]byte, 0, len(q)),
+	}
+	l := NewLexer(q)
+	l.Dialect = DialectPostgreSQL
+	space := false
+	for l.Next() {
+		t := l.Token()
+		switch t.Type {
+		case TokenSpace, TokenComment:
+			space = true
+			continue
+		case TokenHint:
+			if fp.StripHints {
+				space = true
+				continue
+			}
+		}
+		p.toks = append(p.toks, t)
+		p.spaces = append(p.spaces, space)
+		space = false
+	}
+	p.err = l.syntaxError()
+	if p.firstWords() {
+		return p
+	}
+
+	prevWord := ""
+	orderBy := false
+	for i := 0; i < len(p.toks); i++ {
+		t, space := p.toks[i], p.spaces[i]
+		switch t.Type {
+		case TokenHint:
+			p.write(normalizeHint(t.Text, 0), true)
+			p.space = true
+		case TokenNumber, TokenHex, TokenBit, TokenString:
+			p.write("?", space)
+			p.addValue(valueKind(t), t.Start, t.End)
+		case TokenParam:
+			p.write("?", space)
+		case TokenIdent:
+			p.write(strings.ToLower(t.Text), space)
+		case TokenWord:
+			word := strings.ToLower(t.Text)
+			switch {
+			case word == "null" && prevWord != "is" && prevWord != "not":
+				p.write("?", space)
+				p.addValue(ValueNull, t.Start, t.End)
+			case orderBy && word == "asc":
+				// ORDER BY c ASC -> order by c
+			case (word == "in" || word == "values") && p.is(i+1, "("):
+				// IN (1, 2) -> in(?+), VALUES (1), (2) -> values(?+)
+				p.write(word, space)
+				i = p.list(i+1, word == "values")
+			case word == "array" && p.is(i+1, "["):
+				// ARRAY[1, 2] -> array[?+], like ANY(ARRAY[...])
+				p.write(word, space)
+				i = p.list(i+1, false)
+			default:
+				if fp.ReplaceNumbersInWords {
+					word = replaceWordDigits(word)
+				}
+				p.write(word, space)
+			}
+			if prevWord == "order" && word == "by" {
+				orderBy = true
+			}
+			prevWord = word
+			continue
+		case TokenOperator:
+			if (t.Text == "-" || t.Text == "+") && i+1 < len(p.toks) && p.toks[i+1].Type == TokenNumber &&
+				!p.spaces[i+1] && startsExpr(p.toks, i-1, pgKeywords) {
+				// = -1 -> = ?
+				p.write("?", space)
+				p.addValue(ValueNumber, t.Start, p.toks[i+1].End)
+				i++
+				break
+			}
+			p.write(t.Text, space)
+		}
+		prevWord = ""
+	}
+	if !params {
+		p.values = nil
+	}
+	return p
+}
+
+// firstWords fingerprints USE, CALL, and administrator commands by their
+// first word(s) like the MySQL state machine: "USE db" -> "use ?", "CALL
+// sp(1, 2)" -> "call sp", and "administrator command: Quit" is unchanged. It
+// returns false for other queries.
+func (p *pgFingerprint) firstWords() bool {
+	if len(p.toks) == 0 || p.toks[0].Type != TokenWord {
+		return false
+	}
+	switch strings.ToLower(p.toks[0].Text) {
+	case "use":
+		p.f = append(p.f[:0], "use ?"...)
+	case "call":
+		f := "call "
+		i := 1
+		for ; i < len(p.toks) && (p.toks[i].Type == TokenWord || p.toks[i].Type == TokenIdent || p.is(i, ".")); i++ {
+			f += strings.ToLower(p.toks[i].Text) // sp or db.sp
+		}
+		if i == 1 || !p.is(i, "(") {
+			return false
+		}
+		p.f = append(p.f[:0], f...)
+	case "administrator":
+		if len(p.toks) < 3 || strings.ToLower(p.toks[1].Text) != "command" || !p.is(2, ":") {
+			return false
+		}
+		p.f = append(p.f[:0], p.q...)
+	default:
+		return false
+	}
+	return true
+}
+
+// list replaces the list that begins with the ( or [ at toks[i] with (?+) or
+// [?+], or () or [] if it is empty, and returns the index of the closing ) or
+// ]. If rows is true, more lists separated by commas are part of the list,
+// like VALUES (1), (2).
+func (p *pgFingerprint) list(i int, rows bool) int {
+	open := p.toks[i].Text
+	end := p.closing(i)
+	if end < 0 {
+		p.err = &SyntaxError{Offset: p.toks[i].Start, State: "list", Msg: "unbalanced parentheses"}
+		p.write(open, false)
+		return i
+	}
+	if end == i+1 {
+		p.write(open+p.toks[end].Text, false)
+		return end
+	}
+	p.write(open+"?+"+p.toks[end].Text, false)
+	for rows && p.is(end+1, ",") && p.is(end+2, open) {
+		next := p.closing(end + 2)
+		if next < 0 {
+			break
+		}
+		end = next
+	}
+	p.addValue(ValueList, p.toks[i].Start, p.toks[end].End)
+	return end
+}
+
+// closing returns the index of the ) or ] that closes the ( or [ at toks[i],
+// or -1 if it is not closed.
+func (p *pgFingerprint) closing(i int) int {
+	open := p.toks[i].Text
+	close := ")"
+	if open == "[" {
+		close = "]"
+	}
+	depth := 0
+	for j := i; j < len(p.toks); j++ {
+		if p.toks[j].Type != TokenOperator {
+			continue
+		}
+		switch p.toks[j].Text {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// is returns true if toks[i] is the operator op.
+func (p *pgFingerprint) is(i int, op string) bool {
+	return i < len(p.toks) && p.toks[i].Type == TokenOperator && p.toks[i].Text == op
+}
+
+// replaceWordDigits replaces the digits in a word like the MySQL fingerprint
+// with ReplaceNumbersInWords: org235 -> org?, but a leading number is kept
+// because it is not in a word yet, so 123foo45 -> 123foo?.
+func replaceWordDigits(word string) string {
+	i := 0
+	for i < len(word) && word[i] >= '0' && word[i] <= '9' {
+		i++
+	}
+	return word[:i] + replaceDigits(word[i:])
+}
+
+// pgKeywords are the PostgreSQL keywords after which an expression can start.
+// They are the reserved keywords, which cannot be column names, except those
+// that are values like NULL and CURRENT_DATE or postfix like ISNULL, plus
+// BETWEEN, BY, SET, and VALUES.
+var pgKeywords = map[string]bool{}
+
+func init() {
+	for _, kw := range strings.Fields(pgKeywordList) {
+		pgKeywords[kw] = true
+	}
+}
+
+const pgKeywordList = `
+ALL ANALYSE ANALYZE AND ANY ARRAY AS ASC ASYMMETRIC AUTHORIZATION BETWEEN
+BINARY BOTH BY CASE CAST CHECK COLLATE COLLATION COLUMN CONCURRENTLY
+CONSTRAINT CREATE CROSS DEFAULT DEFERRABLE DESC DISTINCT DO ELSE EXCEPT
+FETCH FOR FOREIGN FREEZE FROM FULL GRANT GROUP HAVING ILIKE IN INITIALLY
+INNER INTERSECT INTO IS JOIN LATERAL LEADING LEFT LIKE LIMIT NATURAL NOT
+OFFSET ON ONLY OR ORDER OUTER OVERLAPS PLACING PRIMARY REFERENCES RETURNING
+RIGHT SELECT SET SIMILAR SOME SYMMETRIC TABLE TABLESAMPLE THEN TO TRAILING
+UNION UNIQUE USING VALUES VARIADIC VERBOSE WHEN WHERE WINDOW WITH
+`
+
+func (p *pgFingerprint) write(s string, space bool) {
+	if (space || p.space) && len(p.f) > 0 {
+		p.f = append(p.f, ' ')
+	}
+	p.space = false
+	p.f = append(p.f, s...)
+}
+
+func (p *pgFingerprint) addValue(kind ValueKind, start, end int) {
+	p.values = append(p.values, newValue(kind, p.q, start, end))
+}
+
+// valueKind returns the kind of value t.
+func valueKind(t Token) ValueKind {
+	switch t.Type {
+	case TokenHex:
+		return ValueHex
+	case TokenBit:
+		return ValueBit
+	case TokenString:
+		return ValueString
+	}
+	return ValueNumber
+}
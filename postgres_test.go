@@ -0,0 +1,167 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestFingerprintPostgres(t *testing.T) {
+	fp := &query.Fingerprinter{Dialect: query.DialectPostgreSQL}
+	tests := []struct {
+		q string
+		f string
+	}{
+		// $1 parameters and ::type casts
+		{
+			"SELECT * FROM t WHERE id = $1 AND created_at > '2020-01-01'::date",
+			"select * from t where id = ? and created_at > ?::date",
+		},
+		// ANY(ARRAY[...]) lists like IN lists
+		{
+			"SELECT * FROM t WHERE id = ANY(ARRAY[1,2,3]) AND x = ANY($2::int[]) AND y IN (1, 2)",
+			"select * from t where id = any(array[?+]) and x = any(?::int[]) and y in(?+)",
+		},
+		{
+			"SELECT ARRAY[[1,2],[3,4]], ARRAY[]::int[]",
+			"select array[?+], array[]::int[]",
+		},
+		// Dollar-quoted, E'', U&'', and standard strings without \ escapes
+		{
+			`SELECT $$it's$$, $fn$a $$ b$fn$, E'it\'s', U&'d\0061t', 'C:\' FROM t WHERE c = 1`,
+			"select ?, ?, ?, ?, ? from t where c = ?",
+		},
+		// Double-quoted identifiers
+		{
+			`SELECT "Col" FROM "My Table" WHERE U&"d\0061t" = 'x'`,
+			`select "col" from "my table" where u&"d\0061t" = ?`,
+		},
+		// Nested comments, and -- without a space
+		{
+			"/* outer /* inner */ still */ SELECT 1 --comment\nFROM t",
+			"select ? from t",
+		},
+		// The same as MySQL: VALUES, NULL, ORDER BY ASC, and signs
+		{
+			"INSERT INTO t (a, b) VALUES ($1, NULL), ($3, $4) RETURNING id",
+			"insert into t (a, b) values(?+) returning id",
+		},
+		{
+			"SELECT * FROM t WHERE a IS NULL AND b = -1 AND c-1 > 2 ORDER BY a ASC, b DESC LIMIT 10",
+			"select * from t where a is null and b = ? and c-? > ? order by a, b desc limit ?",
+		},
+		// The same as MySQL: CALL, USE, and administrator commands
+		{
+			"CALL foo(1, 'a')",
+			"call foo",
+		},
+		{
+			`CALL public."My Proc" (1)`,
+			`call public."my proc"`,
+		},
+		{
+			"USE db",
+			"use ?",
+		},
+		{
+			"administrator command: Init DB",
+			"administrator command: Init DB",
+		},
+		// Signs after PostgreSQL keywords, not MySQL keywords like VALUE
+		{
+			"SELECT value -1, -2 FROM t WHERE a BETWEEN -3 AND +4 OFFSET -5",
+			"select value -?, ? from t where a between ? and ? offset ?",
+		},
+		{
+			"SELECT /*+ SeqScan(t) */ * FROM t",
+			"select /*+ seqscan(t) */ * from t",
+		},
+	}
+	for _, test := range tests {
+		if got := fp.Fingerprint(test.q); got != test.f {
+			t.Errorf("%s\ngot:\n%s\nexpected:\n%s\n", test.q, got, test.f)
+		}
+		var buf bytes.Buffer
+		if err := fp.FingerprintReader(strings.NewReader(test.q), &buf); err != nil {
+			t.Error(err)
+		} else if got := buf.String(); got != test.f {
+			t.Errorf("FingerprintReader %s\ngot:\n%s\nexpected:\n%s\n", test.q, got, test.f)
+		}
+	}
+}
+
+func TestFingerprintPostgresNumbersInWords(t *testing.T) {
+	fp := &query.Fingerprinter{Dialect: query.DialectPostgreSQL, ReplaceNumbersInWords: true}
+	// The same as MySQL: a leading number is kept
+	my := &query.Fingerprinter{ReplaceNumbersInWords: true}
+	q := "SELECT c1 FROM org235.t_2x JOIN 123foo45 USING (id)"
+	expect := "select c? from org?.t_?x join 123foo? using (id)"
+	if got := fp.Fingerprint(q); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+	if got := my.Fingerprint(q); got != expect {
+		t.Errorf("MySQL got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+}
+
+func TestParameterizePostgres(t *testing.T) {
+	fp := &query.Fingerprinter{Dialect: query.DialectPostgreSQL}
+	q := "SELECT * FROM t WHERE a = $1 AND b = E'x\\'y' AND c = ANY(ARRAY[1, 2]) AND d = -5"
+	f, values := fp.Parameterize(q)
+	if expect := "select * from t where a = ? and b = ? and c = any(array[?+]) and d = ?"; f != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", f, expect)
+	}
+	expect := []query.Value{
+		{Kind: query.ValueString, Text: `E'x\'y'`, Start: 37, End: 44},
+		{Kind: query.ValueList, Text: "[1, 2]", Start: 62, End: 68},
+		{Kind: query.ValueNumber, Text: "-5", Start: 78, End: 80},
+	}
+	if len(values) != len(expect) {
+		t.Fatalf("got %v, expected %v", values, expect)
+	}
+	for i := range expect {
+		if values[i] != expect[i] {
+			t.Errorf("value %d: got %+v, expected %+v", i, values[i], expect[i])
+		}
+	}
+}
+
+func TestFingerprintStrictPostgres(t *testing.T) {
+	fp := &query.Fingerprinter{Dialect: query.DialectPostgreSQL}
+	tests := map[string]string{
+		"select 'foo":                      "unterminated quoted value at offset 7 (state string)",
+		"select $$foo":                     "unterminated quoted value at offset 7 (state string)",
+		"select 1 /* a /* b */":            "unterminated comment at offset 9 (state comment)",
+		"select * from t where a in (1, 2": "unbalanced parentheses at offset 27 (state list)",
+	}
+	for q, expect := range tests {
+		_, err := fp.FingerprintStrict(q)
+		if err == nil {
+			t.Errorf("%s: no error", q)
+		} else if err.Error() != expect {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s\n", q, err, expect)
+		}
+	}
+	if _, err := fp.FingerprintStrict("select $$a /* b$$ from t"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLexerPostgres(t *testing.T) {
+	l := query.NewLexer("SELECT \"c\", $1::int, $$a'b$$, #`x` -- c")
+	l.Dialect = query.DialectPostgreSQL
+	tokens := []query.Token{}
+	for l.Next() {
+		tokens = append(tokens, l.Token())
+	}
+	expect := "word:SELECT ident:\"c\" operator:, param:$1 operator::: word:int operator:, string:$$a'b$$ operator:, operator:# operator:` word:x operator:` comment:-- c"
+	if got := tokenString(tokens); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+}
@@ -0,0 +1,251 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"strings"
+)
+
+// ProxySQL is the options to fingerprint queries like ProxySQL normalizes
+// them for the digest_text column of stats_mysql_query_digest; see
+// Fingerprinter.ProxySQL. The fields are the ProxySQL mysql-query_digests_*
+// variables of the same name. Use DefaultProxySQL for the ProxySQL defaults;
+// the zero value does not group values or limit the length.
+//
+// Unlike Fingerprint, case and spacing are kept, except that spaces are
+// collapsed to one space:
+//   - Comments are removed but /*! MySQL-specific code */ is kept
+//   - Values are ?, and a negative value like -1 is one value
+//   - A list of values is written without spaces: IN (?,?,?)
+//   - NULL is kept, and digits in identifiers are kept
+//   - A trailing ; is removed
+type ProxySQL struct {
+	Lowercase           bool // lowercase the digest text
+	ReplaceNull         bool // replace NULL with ?
+	NoDigits            bool // replace digits in identifiers with ?
+	GroupingLimit       int  // list values after this many are ",...", like (?,?,?,...)
+	GroupsGroupingLimit int  // rows after this many are ",...", like (?,?),...
+	MaxDigestLength     int  // truncate digest text to this many bytes
+}
+
+// DefaultProxySQL has the ProxySQL 2.x default options.
+var DefaultProxySQL = ProxySQL{
+	GroupingLimit:       3,
+	GroupsGroupingLimit: 10,
+	MaxDigestLength:     2048,
+}
+
+// fingerprintProxySQL makes the ProxySQL digest text of q. A list of rows is
+// one ValueList value, but the values of function arguments, like sleep(1),
+// are not a list.
+func (fp *Fingerprinter) fingerprintProxySQL(q string, params bool) *proxysqlDigest {
+	d := &proxysqlDigest{p: *fp.ProxySQL, q: q}
+	l := NewLexer(q)
+	l.SQLMode = fp.SQLMode
+	for l.Next() {
+		d.add(l.Token())
+	}
+	d.err = l.syntaxError()
+	if n := len(d.toks); n > 0 && d.toks[n-1].kind == proxysqlText && d.toks[n-1].text == ";" {
+		d.toks = d.toks[:n-1]
+	}
+
+	buf := make([]byte, 0, len(q))
+	for _, t := range d.toks {
+		if t.space && len(buf) > 0 {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, t.text...)
+	}
+	d.text = string(buf)
+	if d.p.Lowercase {
+		d.text = strings.ToLower(d.text)
+	}
+	if d.p.MaxDigestLength > 0 && len(d.text) > d.p.MaxDigestLength {
+		d.text = d.text[:d.p.MaxDigestLength]
+	}
+	if !params {
+		d.values = nil
+	}
+	return d
+}
+
+// Kinds of proxysqlToken
+const (
+	proxysqlText  byte = iota // word, identifier, or operator
+	proxysqlValue             // ?
+	proxysqlRow               // (?,?) or rows (?,?),(?,?)
+)
+
+type proxysqlToken struct {
+	kind  byte
+	text  string
+	space bool // space before the token
+	rows  int  // number of rows if kind is proxysqlRow, 0 if function arguments
+	start int  // offset of the token in the query
+	value int  // index in values if kind is proxysqlValue or proxysqlRow, or -1
+}
+
+type proxysqlDigest struct {
+	p      ProxySQL
+	q      string
+	toks   []proxysqlToken
+	text   string
+	values []Value // one for each proxysqlValue, or one list for each row
+	code   []Token // code tokens added, before reduction
+	space  bool    // space or comment since the last token
+	err    *SyntaxError
+}
+
+func (d *proxysqlDigest) add(t Token) {
+	switch t.Type {
+	case TokenSpace, TokenComment, TokenHint, TokenMySQLCode, TokenMySQLCodeEnd:
+		d.space = true
+		return
+	}
+	d.code = append(d.code, t)
+	switch t.Type {
+	case TokenNumber, TokenHex, TokenBit, TokenString:
+		d.addValue(t)
+		return
+	case TokenWord, TokenIdent:
+		if t.Type == TokenWord && d.p.ReplaceNull && strings.ToLower(t.Text) == "null" {
+			d.addValue(t)
+			return
+		}
+		text := t.Text
+		if d.p.NoDigits {
+			text = replaceDigits(text)
+		}
+		d.push(proxysqlToken{kind: proxysqlText, text: text, start: t.Start})
+		return
+	}
+
+	switch t.Text {
+	case "?":
+		d.addValue(t)
+	case ")":
+		d.addParen(t)
+	default:
+		d.push(proxysqlToken{kind: proxysqlText, text: t.Text, start: t.Start})
+	}
+}
+
+// addValue adds ?. A sign right before a number is part of the value if an
+// expression can start before the sign: = -1, (-1, but not a-1.
+func (d *proxysqlDigest) addValue(t Token) {
+	start := t.Start
+	n := len(d.toks)
+	if t.Type == TokenNumber && !d.space && n > 0 && d.toks[n-1].kind == proxysqlText &&
+		(d.toks[n-1].text == "-" || d.toks[n-1].text == "+") && startsExpr(d.code, len(d.code)-3, mysqlKeywords) {
+		d.space = d.toks[n-1].space
+		start = d.toks[n-1].start
+		d.toks = d.toks[:n-1]
+	}
+	value := -1
+	switch t.Type {
+	case TokenOperator:
+		// ? is a placeholder, not a value
+	case TokenWord:
+		value = len(d.values)
+		d.values = append(d.values, newValue(ValueNull, d.q, start, t.End))
+	default:
+		value = len(d.values)
+		d.values = append(d.values, newValue(valueKind(t), d.q, start, t.End))
+	}
+	d.push(proxysqlToken{kind: proxysqlValue, text: "?", start: start, value: value})
+}
+
+// addParen adds a closing parenthesis. A list of only values is reduced to a
+// row, and rows separated by commas are reduced to one row, both grouped by
+// GroupingLimit and GroupsGroupingLimit. The values of a row, or rows, are
+// replaced by one ValueList value.
+func (d *proxysqlDigest) addParen(t Token) {
+	// Find the ( of the list: value [, value]...
+	nValues := 0
+	i := len(d.toks) - 1
+	for ; i >= 0; i -= 2 {
+		if d.toks[i].kind != proxysqlValue {
+			break
+		}
+		nValues++
+		if i == 0 || d.toks[i-1].kind != proxysqlText || d.toks[i-1].text != "," {
+			i--
+			break
+		}
+	}
+	if nValues == 0 || i < 0 || d.toks[i].kind != proxysqlText || d.toks[i].text != "(" {
+		d.push(proxysqlToken{kind: proxysqlText, text: ")", start: t.Start})
+		return
+	}
+
+	first := len(d.values) // first value in the list
+	for j := i + 1; j < len(d.toks); j += 2 {
+		if v := d.toks[j].value; v >= 0 && v < first {
+			first = v
+		}
+	}
+	row := "("
+	for v := 0; v < nValues; v++ {
+		if d.p.GroupingLimit > 0 && v == d.p.GroupingLimit {
+			row += ",..."
+			break
+		}
+		if v > 0 {
+			row += ","
+		}
+		row += "?"
+	}
+	row += ")"
+	space, start := d.toks[i].space, d.toks[i].start
+	d.toks = d.toks[:i]
+	d.space = false
+
+	// Rows: (?,?),(?,?)
+	n := len(d.toks)
+	if n > 1 && d.toks[n-1].kind == proxysqlText && d.toks[n-1].text == "," && d.toks[n-2].kind == proxysqlRow && d.toks[n-2].rows > 0 {
+		d.toks = d.toks[:n-1]
+		prev := &d.toks[n-2]
+		prev.rows++
+		switch {
+		case d.p.GroupsGroupingLimit <= 0 || prev.rows <= d.p.GroupsGroupingLimit:
+			prev.text += "," + row
+		case prev.rows == d.p.GroupsGroupingLimit+1:
+			prev.text += ",..."
+		}
+		d.values = d.values[:first]
+		d.values[prev.value] = newValue(ValueList, d.q, d.values[prev.value].Start, t.End)
+		return
+	}
+	// Function arguments, like sleep(?), are not a row.
+	if n > 0 && d.toks[n-1].kind == proxysqlText && isWordChar(rune(d.toks[n-1].text[0])) &&
+		!wordIn(d.toks[n-1].text, "values", "value", "in") {
+		d.toks = append(d.toks, proxysqlToken{kind: proxysqlRow, text: row, space: space, start: start, value: -1})
+		return
+	}
+	d.values = append(d.values[:first], newValue(ValueList, d.q, start, t.End))
+	d.toks = append(d.toks, proxysqlToken{kind: proxysqlRow, text: row, space: space, rows: 1, start: start, value: first})
+}
+
+func (d *proxysqlDigest) push(t proxysqlToken) {
+	t.space = d.space
+	d.space = false
+	d.toks = append(d.toks, t)
+}
+
+// replaceDigits replaces each run of digits in s with ?.
+func replaceDigits(s string) string {
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			if i == 0 || s[i-1] < '0' || s[i-1] > '9' {
+				buf = append(buf, '?')
+			}
+			continue
+		}
+		buf = append(buf, s[i])
+	}
+	return string(buf)
+}
@@ -0,0 +1,143 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestProxySQL(t *testing.T) {
+	tests := []struct {
+		q    string
+		text string
+	}{
+		{
+			"SELECT c FROM sbtest1 WHERE id=10",
+			"SELECT c FROM sbtest1 WHERE id=?",
+		},
+		{
+			"SELECT  DISTINCT c FROM sbtest1\n\tWHERE id BETWEEN 5 AND 5+99 ORDER BY c",
+			"SELECT DISTINCT c FROM sbtest1 WHERE id BETWEEN ? AND ?+? ORDER BY c",
+		},
+		{
+			"select * from t where id in (1, 2,3,4,5) and b = -1 and c-1 > 0;",
+			"select * from t where id in (?,?,?,...) and b = ? and c-? > ?",
+		},
+		{
+			"INSERT INTO t (a, b) VALUES (1, 'x'), (2, \"y\"),(3,NULL)",
+			"INSERT INTO t (a, b) VALUES (?,?),(?,?),(?,NULL)",
+		},
+		{
+			"/* app:web */ SELECT /*!40001 SQL_NO_CACHE */ * FROM db2.t1 WHERE x IS NULL -- c\n",
+			"SELECT SQL_NO_CACHE * FROM db2.t1 WHERE x IS NULL",
+		},
+		{
+			"SELECT sleep(1), (2)",
+			"SELECT sleep(?), (?)",
+		},
+		{
+			"UPDATE t SET a=? WHERE b IN (?,?,?,?,?)",
+			"UPDATE t SET a=? WHERE b IN (?,?,?,...)",
+		},
+	}
+	fp := &query.Fingerprinter{ProxySQL: &query.DefaultProxySQL, IdHash: query.IdHashSpooky}
+	for _, test := range tests {
+		if got := fp.Fingerprint(test.q); got != test.text {
+			t.Errorf("got:\n%s\nexpected:\n%s\n", got, test.text)
+		}
+	}
+}
+
+func TestProxySQLDigest(t *testing.T) {
+	// Digests shown by ProxySQL in stats_mysql_query_digest for the
+	// digest_text. ProxySQL 1.4 showed the low 32 bits first, so the first
+	// digest was shown as 0x13781C1DBF001A0C.
+	tests := []struct {
+		q      string
+		text   string
+		digest query.ID
+	}{
+		{
+			"SELECT c FROM sbtest1 WHERE id=10",
+			"SELECT c FROM sbtest1 WHERE id=?",
+			0xBF001A0C13781C1D,
+		},
+		{
+			"INSERT INTO sbtest1 (id, k, c, pad) VALUES (?, ?, ?, ?)",
+			"INSERT INTO sbtest1 (id, k, c, pad) VALUES (?, ?, ?, ?)",
+			0xE52A0A0210634DAC,
+		},
+	}
+	fp := &query.Fingerprinter{ProxySQL: &query.DefaultProxySQL, IdHash: query.IdHashSpooky}
+	for _, test := range tests {
+		if got := fp.ID(test.text); got != test.digest {
+			t.Errorf("%s: got digest 0x%s, expected 0x%s", test.text, got, test.digest)
+		}
+	}
+	if got := fp.ID(fp.Fingerprint(tests[0].q)); got != tests[0].digest {
+		t.Errorf("%s: got digest 0x%s, expected 0x%s", tests[0].q, got, tests[0].digest)
+	}
+}
+
+func TestParameterizeProxySQL(t *testing.T) {
+	fp := &query.Fingerprinter{ProxySQL: &query.DefaultProxySQL}
+	q := "INSERT INTO t VALUES (1, 'a'), (-2, 'b') ON DUPLICATE KEY UPDATE c=sleep(3)"
+	f := "INSERT INTO t VALUES (?,?),(?,?) ON DUPLICATE KEY UPDATE c=sleep(?)"
+	got, values := fp.Parameterize(q)
+	if got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+	expect := []query.Value{
+		{Kind: query.ValueList, Text: "(1, 'a'), (-2, 'b')", Start: 21, End: 40},
+		{Kind: query.ValueNumber, Text: "3", Start: 73, End: 74},
+	}
+	if !reflect.DeepEqual(values, expect) {
+		t.Errorf("got values %+v, expected %+v", values, expect)
+	}
+
+	// A truncated query
+	if _, err := fp.FingerprintStrict("SELECT c FROM t WHERE s='abc"); err == nil {
+		t.Error("got nil error, expected unterminated quoted value")
+	}
+}
+
+func TestProxySQLOptions(t *testing.T) {
+	p := query.ProxySQL{
+		Lowercase:           true,
+		ReplaceNull:         true,
+		NoDigits:            true,
+		GroupingLimit:       2,
+		GroupsGroupingLimit: 1,
+		MaxDigestLength:     40,
+	}
+	tests := []struct {
+		q    string
+		text string
+	}{
+		{
+			"SELECT c FROM sbtest1 WHERE id IN (1, 2, 3) AND d IS NULL",
+			"select c from sbtest? where id in (?,?,.",
+		},
+		{
+			"INSERT INTO t VALUES (1, 'x'), (2, NULL), (3, 'z')",
+			"insert into t values (?,?),...",
+		},
+	}
+	fp := &query.Fingerprinter{ProxySQL: &p}
+	for _, test := range tests {
+		if got := fp.Fingerprint(test.q); got != test.text {
+			t.Errorf("got:\n%s\nexpected:\n%s\n", got, test.text)
+		}
+	}
+
+	// The zero value does not group values.
+	fp = &query.Fingerprinter{ProxySQL: &query.ProxySQL{}}
+	if got, expect := fp.Fingerprint("SELECT 1 FROM t WHERE id IN (1,2,3,4,5)"), "SELECT ? FROM t WHERE id IN (?,?,?,?,?)"; got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+}
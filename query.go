@@ -54,9 +54,7 @@ package query
 */
 
 import (
-	"crypto/md5"
 	"fmt"
-	"io"
 	"strings"
 )
 
@@ -81,6 +79,9 @@ const (
 	inNumberInWord           // e.g. db23
 	inBackticks              // `table-1`
 	inMySQLCode              // /*! MySQL-specific code */
+	inHint                   // /*+ optimizer hints */
+	inVersion                // NNNNN in /*!NNNNN MySQL-specific code */
+	mlcOrMariaDBCode         // /*M comment */ or /*M! MariaDB-specific code */
 )
 
 var stateName map[byte]string = map[byte]string{
@@ -104,642 +105,943 @@ var stateName map[byte]string = map[byte]string{
 	17: "inNumberInWord",
 	18: "inBackticks",
 	19: "inMySQLCode",
+	20: "inHint",
+	21: "inVersion",
+	22: "mlcOrMariaDBCode",
 }
 
-// Debug prints very verbose tracing information to STDOUT.
-var Debug bool = false
+// A Fingerprinter fingerprints queries. Every option that changes how queries
+// are fingerprinted is a field, so different configurations can be used at
+// the same time in one process. The zero value is ready to use and produces
+// the same fingerprints as Fingerprint. A Fingerprinter is safe for concurrent
+// use as long as its fields are not modified.
+type Fingerprinter struct {
+	// Tracer receives an event for every rune of the query as it is
+	// fingerprinted. To trace one call, trace a copy of the Fingerprinter:
+	//
+	//	tfp := *fp
+	//	tfp.Tracer = query.NewTextTracer(os.Stderr)
+	//	f := tfp.Fingerprint(q)
+	Tracer Tracer
 
-// ReplaceNumbersInWords enables replacing numbers in words. For example:
-// `SELECT c FROM org235.t` -> `SELECT c FROM org?.t`. For more examples
-// look at test query_test.go/TestFingerprintWithNumberInDbName.
-var ReplaceNumbersInWords = false
+	// Debug prints very verbose tracing information to STDOUT. It is the
+	// same as setting Tracer to NewTextTracer(os.Stdout), and it is ignored
+	// if Tracer is set.
+	//
+	// Deprecated: use Tracer.
+	Debug bool
+
+	// ReplaceNumbersInWords enables replacing numbers in words. For example:
+	// `SELECT c FROM org235.t` -> `SELECT c FROM org?.t`. For more examples
+	// look at test query_test.go/TestFingerprintWithNumberInDbName.
+	ReplaceNumbersInWords bool
+
+	// StripHints removes /*+ optimizer hints */ like other comments. By
+	// default, hints are kept because they change the query plan, so a
+	// query with hints is a different class than the query without them.
+	// Hints are normalized like the query: "/*+ INDEX(t idx)
+	// MAX_EXECUTION_TIME(1000) */" -> "/*+ index(t idx) max_execution_time(?) */".
+	StripHints bool
+
+	// ServerVersion evaluates /*!NNNNN MySQL-specific code */ like a server
+	// of this version: if NNNNN is less than or equal to the version, or there
+	// is no version like /*! code */, the code is part of the query, else it
+	// is a comment. /*M!NNNNN MariaDB-specific code */ is the same, but it is
+	// always a comment if the version is not MariaDB. For example, with
+	// version 8.0.34, "SELECT /*!40001 SQL_NO_CACHE */ * FROM t" -> "select
+	// sql_no_cache * from t" and "SELECT /*!90000 SQL_NO_CACHE */ * FROM t"
+	// -> "select * from t". By default (the zero Version), MySQL- and
+	// MariaDB-specific code is kept unchanged. Use ParseVersion to parse a
+	// version like "8.0.34" or "10.6.12-MariaDB".
+	ServerVersion Version
+
+	// SQLMode is the server sql_mode that changes how quoted values are
+	// parsed. With ANSIQuotes, "col" is an identifier like `col`, so it is
+	// not replaced with ?. With NoBackslashEscapes, \ is not an escape char,
+	// so 'C:\' is a complete value. Use ParseSQLMode to parse a server
+	// sql_mode like "ANSI_QUOTES,NO_BACKSLASH_ESCAPES".
+	SQLMode SQLMode
+
+	// Dialect is the SQL dialect of queries. The default is DialectMySQL.
+	// With DialectPostgreSQL, queries are fingerprinted with the same
+	// transformations, plus PostgreSQL syntax like $1 parameters and
+	// ARRAY[...] lists: "SELECT * FROM t WHERE id = ANY(ARRAY[1,2]) AND
+	// c = $1" -> "select * from t where id = any(array[?+]) and c = ?".
+	// Tracer, ServerVersion, and SQLMode are MySQL-only.
+	Dialect Dialect
+
+	// ProxySQL, if set, fingerprints queries like ProxySQL normalizes them
+	// for stats_mysql_query_digest, with these options, instead of with the
+	// transformations of Fingerprint: "SELECT c FROM t WHERE id IN (1, 2)"
+	// -> "SELECT c FROM t WHERE id IN (?,?)". With IdHashSpooky, ID is the
+	// ProxySQL digest, which ProxySQL shows like "0x"+ID.String(). Use
+	// &DefaultProxySQL for the ProxySQL defaults. Only SQLMode and IdHash
+	// apply with ProxySQL; queries are MySQL.
+	ProxySQL *ProxySQL
+
+	// IdHash is the hash algorithm used by ID. The default, IdHashMD5,
+	// computes the same IDs as Id.
+	IdHash IdHash
+}
+
+// defaultFingerprinter is used by the package-level functions.
+var defaultFingerprinter = &Fingerprinter{}
+
+// Fingerprint returns the canonical form of q using the default options.
+// It is the same as calling Fingerprint on a zero Fingerprinter.
+func Fingerprint(q string) string {
+	return defaultFingerprinter.Fingerprint(q)
+}
 
 // Fingerprint returns the canonical form of q. The primary transformations are:
 //   - Replace values with ?
 //   - Collapse whitespace
 //   - Remove comments
 //   - Lowercase everything
+//
 // Additional trasnformations are performed which change the syntax of the
 // original query without affecting its performance characteristics. For
 // example, "ORDER BY col ASC" is the same as "ORDER BY col", so "ASC" in the
 // fingerprint is removed.
-func Fingerprint(q string) string {
-	q += " " // need range to run off end of original query
-	prevWord := ""
-	f := make([]byte, len(q))
-	fi := 0
-	pr := rune(0) // previous rune
-	s := unknown  // current state
-	sqlState := unknown
-	quoteChar := rune(0)
-	cpFromOffset := 0
-	cpToOffset := 0
-	addSpace := false
-	escape := false
-	parOpen := 0
-	parOpenTotal := 0
-	valueNo := 0
-	firstPar := 0
+func (fp *Fingerprinter) Fingerprint(q string) string {
+	f, _ := fp.fingerprint(q, false)
+	return f
+}
+
+// Parameterize is like Fingerprint but it also returns the literal values
+// replaced in the fingerprint, in the order they appear in q. A value list
+// like "VALUES (1, 2), (3, 4)" or "IN (1, 2)" is replaced by a single ?+, so
+// it is returned as a single ValueList value. Identifiers are not values, so
+// numbers replaced in words, the database in "USE db", and the arguments of
+// "CALL sp(...)" are not returned. Nor are values in /*+ optimizer hints */.
+func Parameterize(q string) (string, []Value) {
+	return defaultFingerprinter.Parameterize(q)
+}
+
+// Parameterize is like Fingerprint but it also returns the literal values
+// replaced in the fingerprint. See the package-level Parameterize.
+func (fp *Fingerprinter) Parameterize(q string) (string, []Value) {
+	return fp.fingerprint(q, true)
+}
 
+// fingerprint fingerprints q and, if params is true, returns the values
+// replaced in the fingerprint.
+func (fp *Fingerprinter) fingerprint(q string, params bool) (string, []Value) {
+	if fp.ProxySQL != nil {
+		d := fp.fingerprintProxySQL(q, params)
+		return d.text, d.values
+	}
+	if fp.Dialect == DialectPostgreSQL {
+		p := fp.fingerprintPostgres(q, params)
+		return string(p.f), p.values
+	}
+	_, f, values := fp.run(q, params)
+	return f, values
+}
+
+// run runs the state machine on q and returns it with the fingerprint.
+func (fp *Fingerprinter) run(q string, params bool) (*machine, string, []Value) {
+	q += " " // need range to run off end of original query
+	m := newMachine(fp, params)
+	m.q = q
+	m.f = make([]byte, 0, len(q))
 	for qi, r := range q {
-		if Debug {
-			fmt.Printf("\n%d:%d %s/%s [%d:%d] %x %q\n", qi, fi, stateName[s], stateName[sqlState], cpFromOffset, cpToOffset, r, r)
-		}
-
-		/**
-		 * 1. Skip parts of the query for certain states.
-		 */
-
-		if s == inQuote || s == inBackticks {
-			// We're in a 'quoted value' or "quoted value", or in a backtick-quoted
-			// ident like `foo-tbl`. The value ends at the first non-escaped matching
-			// quote character (' or " or `).
-			if r != quoteChar {
-				// The only char inside a quoted value we need to track is \,
-				// the escape char.  This allows us to tell that the 2nd ' in
-				// '\'' is escaped, not the ending quote char.
-				if escape {
-					if Debug {
-						fmt.Println("Ignore quoted literal")
-					}
-					escape = false
-				} else if r == '\\' {
-					if Debug {
-						fmt.Println("Escape")
-					}
-					escape = true
-				} else {
-					if Debug {
-						fmt.Println("Ignore quoted value")
-					}
-				}
-			} else if escape {
-				// \' or \"
-				if Debug {
-					fmt.Println("Quote literal")
-				}
-				escape = false
+		m.step(qi, r)
+		if m.done {
+			break
+		}
+	}
+	if m.admin {
+		return m, q[0 : len(q)-1], nil // original query minus the trailing space we added
+	}
+	if m.done {
+		return m, m.result, nil
+	}
+
+	// Remove trailing spaces.
+	m.trimSpace()
+
+	// Clean up control characters, and return the fingerprint
+	return m, strings.Replace(string(m.f), "\x00", "", -1), m.values
+}
+
+// A machine is the fingerprint state machine. It is fed one rune at a time
+// by step, so it can fingerprint a query all at once or, for FingerprintReader,
+// as it is read. Offsets like qi and cpFromOffset are offsets in the whole
+// query, but q can be only a window of it that begins at offset base. The
+// fingerprint is appended to f.
+type machine struct {
+	fp     *Fingerprinter
+	params bool // save values; q must be the whole query
+
+	q    string // query, or a window of it
+	base int    // offset of q[0] in the query
+	f    []byte // fingerprint
+	fo   int    // offset of f[0] in the fingerprint, for tracing
+
+	prevWord     string
+	pr           rune // previous rune
+	s            byte // current state
+	sqlState     byte
+	quoteChar    rune
+	cpFromOffset int
+	cpToOffset   int
+	addSpace     bool
+	escape       bool
+	parOpen      int
+	parOpenTotal int
+	valueNo      int
+	firstPar     int
+	values       []Value // only if params is true
+	listValue    int     // index of the value for the current VALUES/IN list
+	quoteStart   int     // offset of first quote char, or the x/b in x'0F'/b'01'
+	quoteKind    ValueKind
+	quoteEnd     int  // offset after the closing quote of the last quoted value
+	numStart     int  // offset of the first char (or sign) of a number
+	openStart    int  // offset of the current quote, backtick, or /* comment
+	version      int  // NNNNN in /*!NNNNN, if ServerVersion is set
+	versionLen   int  // number of digits in version
+	mariaDB      bool // version is in /*M!NNNNNN MariaDB-specific code */
+	inCode       bool // in /*! MySQL-specific code */ that is part of the query
+
+	// copies is the number of copies into f. USE, CALL, and administrator
+	// commands are only detected by the first copy, so once there are two
+	// copies, the fingerprint cannot be replaced by result.
+	copies int
+	done   bool   // fingerprint is result, or the query if admin
+	result string // "use ?" or "call sp_name"
+	admin  bool   // administrator command
+
+	tracer  Tracer
+	reasons []string // for the trace event of the current step
+	copied  string   // word copied into f in the current step
+}
+
+func newMachine(fp *Fingerprinter, params bool) *machine {
+	return &machine{
+		fp:        fp,
+		params:    params,
+		tracer:    fp.tracer(),
+		s:         unknown,
+		sqlState:  unknown,
+		listValue: -1,
+		quoteKind: ValueString,
+	}
+}
+
+// query returns the query from offset i to j.
+func (m *machine) query(i, j int) string {
+	return m.q[i-m.base : j-m.base]
+}
+
+// copyWord appends exactly n bytes of prevWord to f. Lowercasing can change
+// the length of some multi-byte runes, so prevWord is truncated or padded
+// with NUL bytes which are removed from the final fingerprint.
+func (m *machine) copyWord(n int) {
+	w := m.prevWord
+	if len(w) > n {
+		w = w[:n]
+	}
+	m.f = append(m.f, w...)
+	for i := len(w); i < n; i++ {
+		m.f = append(m.f, 0)
+	}
+}
+
+// copyBefore copies the word from cpFromOffset to offset end, if any, into f.
+// It is used when something that ends a word without a space, like a /*+ hint
+// in SELECT/*+ BKA(t1) */, is not copied.
+func (m *machine) copyBefore(end int) {
+	if m.cpFromOffset >= end {
+		return
+	}
+	w := strings.ToLower(strings.TrimSpace(m.query(m.cpFromOffset, end)))
+	if w == "" {
+		return
+	}
+	m.prevWord = w
+	if m.tracer != nil {
+		m.copied = w
+	}
+	m.f = append(m.f, w...)
+	m.copies++
+}
+
+// appendSpace appends a space to f if it does not end with one.
+func (m *machine) appendSpace() {
+	if len(m.f) > 0 && !isSpace(rune(m.f[len(m.f)-1])) {
+		m.f = append(m.f, ' ')
+	}
+}
+
+// backslashEscapes returns true if \ is the escape char in the current quoted
+// value or identifier. With ANSIQuotes, "col" is an identifier which, like a
+// string with NoBackslashEscapes, has no escape char.
+func (m *machine) backslashEscapes() bool {
+	if m.fp.SQLMode&NoBackslashEscapes != 0 {
+		return false
+	}
+	return m.s != inBackticks || m.quoteChar != '"'
+}
+
+// trimSpace removes trailing spaces from f.
+func (m *machine) trimSpace() {
+	for len(m.f) > 0 && isSpace(rune(m.f[len(m.f)-1])) {
+		m.f = m.f[:len(m.f)-1]
+	}
+}
+
+// step processes rune r at offset qi and traces it.
+func (m *machine) step(qi int, r rune) {
+	if m.tracer == nil {
+		m.process(qi, r)
+		return
+	}
+	e := TraceEvent{
+		Offset:            qi,
+		FingerprintOffset: m.fo + len(m.f),
+		Rune:              r,
+		OldState:          stateName[m.s],
+		CopyFrom:          m.cpFromOffset,
+		CopyTo:            m.cpToOffset,
+	}
+	m.reasons = m.reasons[:0]
+	m.copied = ""
+	m.process(qi, r)
+	e.NewState = stateName[m.s]
+	e.SQLState = stateName[m.sqlState]
+	e.Reason = strings.Join(m.reasons, "; ")
+	e.Copied = m.copied
+	m.tracer.Trace(e)
+}
+
+// trace saves the reason for the trace event of the current step.
+func (m *machine) trace(reason string) {
+	if m.tracer != nil {
+		m.reasons = append(m.reasons, reason)
+	}
+}
+
+// process processes rune r at offset qi.
+func (m *machine) process(qi int, r rune) {
+
+	/**
+	 * 1. Skip parts of the query for certain states.
+	 */
+
+	if m.s == inQuote || m.s == inBackticks {
+		// We're in a 'quoted value' or "quoted value", or in a backtick-quoted
+		// ident like `foo-tbl`. The value ends at the first non-escaped matching
+		// quote character (' or " or `).
+		if r != m.quoteChar {
+			// The only char inside a quoted value we need to track is \,
+			// the escape char.  This allows us to tell that the 2nd ' in
+			// '\'' is escaped, not the ending quote char.
+			if m.escape {
+				m.trace("Ignore quoted literal")
+				m.escape = false
+			} else if r == '\\' && m.backslashEscapes() {
+				m.trace("Escape")
+				m.escape = true
 			} else {
-				if Debug {
-					fmt.Println("Quote end")
-				}
-				escape = false
-				if s == inQuote {
-					// 'foo' -> ?
-					// "foo" -> ?
-					// qi = the closing quote char, so +1 to ensure we don't copy
-					// anything before this, i.e. quoted value is done, move on.
-					cpFromOffset = qi + 1
-
-					if sqlState == inValues {
-						// ('Hello world!', ...) -> VALUES (, ...)
-						// The inValues state uses this state to skip quoted values,
-						// so we don't replace them with ?; the inValues blocks will
-						// replace the entire value list with ?+.
-						s = inValues
-					} else {
-						f[fi] = '?'
-						fi++
-						s = unknown
-					}
-				} else { // inBackticks
-					cpToOffset = qi + 1
-					s = inWord
-				}
+				m.trace("Ignore quoted value")
 			}
-			continue
-		} else if s == inNumberInWord {
-			// Replaces number in words with ?
-			// e.g. `db37` to `db?`
-			// Parser can fall into inNumberInWord only if
-			// option ReplaceNumbersInWords is turned on
-			if r >= '0' && r <= '9' {
-				if Debug {
-					fmt.Println("Ignore digit in word")
+		} else if m.escape {
+			// \' or \"
+			m.trace("Quote literal")
+			m.escape = false
+		} else {
+			m.trace("Quote end")
+			m.escape = false
+			if m.s == inQuote {
+				// 'foo' -> ?
+				// "foo" -> ?
+				// qi = the closing quote char, so +1 to ensure we don't copy
+				// anything before this, i.e. quoted value is done, move on.
+				m.cpFromOffset = qi + 1
+				m.quoteEnd = qi + 1
+
+				if m.sqlState == inValues {
+					// ('Hello world!', ...) -> VALUES (, ...)
+					// The inValues state uses this state to skip quoted values,
+					// so we don't replace them with ?; the inValues blocks will
+					// replace the entire value list with ?+.
+					m.s = inValues
+				} else {
+					m.f = append(m.f, '?')
+					m.s = unknown
+					if m.params {
+						m.values = append(m.values, newValue(m.quoteKind, m.q, m.quoteStart, qi+1))
+					}
 				}
-				continue
+			} else { // inBackticks
+				m.cpToOffset = qi + 1
+				m.s = inWord
 			}
+		}
+		return
+	} else if m.s == inNumberInWord {
+		// Replaces number in words with ?
+		// e.g. `db37` to `db?`
+		// Parser can fall into inNumberInWord only if
+		// option ReplaceNumbersInWords is turned on
+		if r >= '0' && r <= '9' {
+			m.trace("Ignore digit in word")
+			return
+		}
+		// 123 -> ?, 0xff -> ?, 1e-9 -> ?, etc.
+		m.trace("Number in word end")
+		m.f = append(m.f, '?')
+		m.cpFromOffset = qi
+		if isSpace(r) {
+			m.s = unknown
+		} else {
+			m.s = inWord
+		}
+	} else if m.s == inNumber {
+		// We're in a number which can be something simple like 123 or
+		// something trickier like 1e-9 or 0xFF.  The pathological case is
+		// like 12ff: this is valid hex number and a valid ident (e.g. table
+		// name).  We can't detect this; the best we can do is realize that
+		// 12ffz is not a number because of the z.
+		if (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F') || r == '.' || r == 'x' || r == '-' {
+			m.trace("Ignore digit")
+			return
+		}
+		if (r >= 'g' && r <= 'z') || (r >= 'G' && r <= 'Z') || r == '_' {
+			m.trace("Not a number")
+			m.cpToOffset = qi
+			m.s = inWord
+		} else if m.sqlState == inMySQLCode {
+			// If we are in /*![version] ... */, keep the version number
+			m.cpToOffset = qi
+			m.s = inWord
+			m.sqlState = unknown
+		} else {
 			// 123 -> ?, 0xff -> ?, 1e-9 -> ?, etc.
-			if Debug {
-				fmt.Println("Number in word end")
-			}
-			f[fi] = '?'
-			fi++
-			cpFromOffset = qi
-			if isSpace(r) {
-				s = unknown
-			} else {
-				s = inWord
-			}
-		} else if s == inNumber {
-			// We're in a number which can be something simple like 123 or
-			// something trickier like 1e-9 or 0xFF.  The pathological case is
-			// like 12ff: this is valid hex number and a valid ident (e.g. table
-			// name).  We can't detect this; the best we can do is realize that
-			// 12ffz is not a number because of the z.
-			if (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F') || r == '.' || r == 'x' || r == '-' {
-				if Debug {
-					fmt.Println("Ignore digit")
-				}
-				continue
-			}
-			if (r >= 'g' && r <= 'z') || (r >= 'G' && r <= 'Z') || r == '_' {
-				if Debug {
-					fmt.Println("Not a number")
-				}
-				cpToOffset = qi
-				s = inWord
-			} else if sqlState == inMySQLCode {
-				// If we are in /*![version] ... */, keep the version number
-				cpToOffset = qi
-				s = inWord
-				sqlState = unknown
-			} else {
-				// 123 -> ?, 0xff -> ?, 1e-9 -> ?, etc.
-				if Debug {
-					fmt.Println("Number end")
-				}
-				f[fi] = '?'
-				fi++
-				cpFromOffset = qi
-				cpToOffset = qi
-				s = unknown
+			m.trace("Number end")
+			m.f = append(m.f, '?')
+			m.cpFromOffset = qi
+			m.cpToOffset = qi
+			m.s = unknown
+			if m.params {
+				m.values = append(m.values, newValue(numberKind(m.q[m.numStart:qi]), m.q, m.numStart, qi))
 			}
-		} else if s == inValues {
-			// We're in the (val1),...,(valN) after IN or VALUE[S].  A single
-			// () value ends when the parenthesis are balanced, but...
-			if r == ')' {
-				parOpen--
-				parOpenTotal++
-				if Debug {
-					fmt.Println("Close parenthesis", parOpen)
-				}
-			} else if r == '(' {
-				parOpen++
-				if Debug {
-					fmt.Println("Open parenthesis", parOpen)
-				}
-				if parOpen == 1 {
-					firstPar = qi
-				}
-			} else if r == '\'' || r == '"' {
-				// VALUES ('Hello world!') -> enter inQuote state to skip
-				// the quoted value so ')' in 'This ) is a trick' doesn't
-				// balance an outer parenthesis.
-				if Debug {
-					fmt.Println("Quote begin")
-				}
-				s = inQuote
-				quoteChar = r
-				continue
-			} else if isSpace(r) {
-				if Debug {
-					fmt.Println("Space")
-				}
-				continue
+		}
+	} else if m.s == inValues {
+		// We're in the (val1),...,(valN) after IN or VALUE[S].  A single
+		// () value ends when the parenthesis are balanced, but...
+		if r == ')' {
+			m.parOpen--
+			m.parOpenTotal++
+			if m.tracer != nil {
+				m.trace(fmt.Sprintf("Close parenthesis %d", m.parOpen))
 			}
-			if parOpen > 0 {
-				// Parenthesis are not balanced yet; i.e. haven't reached
-				// closing ) for this value.
-				continue
+		} else if r == '(' {
+			m.parOpen++
+			if m.tracer != nil {
+				m.trace(fmt.Sprintf("Open parenthesis %d", m.parOpen))
 			}
-			if parOpenTotal == 0 {
-				// SELECT value FROM t
-				if Debug {
-					fmt.Println("Literal values not VALUES()")
-				}
-				s = inWord
-				continue
+			if m.parOpen == 1 {
+				m.firstPar = qi
 			}
-			// (<anything>) -> (?+) only for first value
-			if Debug {
-				fmt.Println("Values end")
-			}
-			valueNo++
-			if valueNo == 1 {
-				if qi-firstPar > 1 {
-					copy(f[fi:fi+4], "(?+)")
-					fi += 4
-				} else {
-					// INSERT INTO t VALUES ()
-					copy(f[fi:fi+2], "()")
-					fi += 2
-				}
-				firstPar = 0
-			}
-			// ... the difficult part is that there may be other values, e.g.
-			// (1), (2), (3).  So we enter the following state.  The values list
-			// ends when the next char is not a comma.
-			s = moreValuesOrUnknown
-			pr = r
-			cpFromOffset = qi + 1
-			parOpenTotal = 0
-			continue
-		} else if s == inMLC {
-			// We're in a /* mutli-line comments */.  Skip and ignore it all.
-			if pr == '*' && r == '/' {
-				// /* foo */ -> (nothing)
-				if Debug {
-					fmt.Println("Multi-line comment end")
-				}
-				s = unknown
-			} else {
-				if Debug {
-					fmt.Println("Ignore multi-line comment content")
-				}
-			}
-			pr = r // save previous rune so we can match */
-			continue
-		} else if s == mlcOrMySQLCode {
-			// We're at the start of either a /* multi-line comment */ or some
-			// /*![version] some MySQL-specific code */.  The ! after the /*
-			// determines which one.
-			if r != '!' {
-				if Debug {
-					fmt.Println("Multi-line comment")
+		} else if r == '\'' || r == '"' {
+			// VALUES ('Hello world!') -> enter inQuote state to skip
+			// the quoted value so ')' in 'This ) is a trick' doesn't
+			// balance an outer parenthesis.
+			m.trace("Quote begin")
+			m.s = inQuote
+			m.quoteChar = r
+			m.openStart = qi
+			return
+		} else if isSpace(r) {
+			m.trace("Space")
+			return
+		} else if r == '/' && m.parOpen == 0 {
+			// IN /* comment */ (1, 2) or IN /*!40001 (1, 2) */: handle
+			// the comment or code like anywhere else; the code may be
+			// the values.
+			m.trace("Comment or MySQL-specific code before values")
+			m.s = divOrMLC
+			m.pr = r
+			return
+		}
+		if m.parOpen > 0 {
+			// Parenthesis are not balanced yet; i.e. haven't reached
+			// closing ) for this value.
+			return
+		}
+		if m.parOpenTotal == 0 {
+			// SELECT value FROM t
+			m.trace("Literal values not VALUES()")
+			m.s = inWord
+			return
+		}
+		// (<anything>) -> (?+) only for first value
+		m.trace("Values end")
+		m.valueNo++
+		if m.valueNo == 1 {
+			m.listValue = -1
+			if qi-m.firstPar > 1 {
+				m.f = append(m.f, "(?+)"...)
+				if m.params {
+					m.listValue = len(m.values)
+					m.values = append(m.values, newValue(ValueList, m.q, m.firstPar, qi+1))
 				}
-				s = inMLC
-				continue
 			} else {
-				// /*![version] SQL_NO_CACHE */ -> /*![version] SQL_NO_CACHE */ (no change)
-				if Debug {
-					fmt.Println("MySQL-specific code")
-				}
-				s = inWord
-				sqlState = inMySQLCode
+				// INSERT INTO t VALUES ()
+				m.f = append(m.f, "()"...)
 			}
-		} else if s == inOLC {
-			// We're in a -- one line comment.  A space after -- is required.
-			// It ends at the end of the line, but there can be more query after
-			// it like:
-			//   SELECT * -- comment
-			//   FROM t
-			// is really "SELECT * FROM t".
-			if r == 0x0A { // newline
-				if Debug {
-					fmt.Println("One-line comment end")
-				}
-				s = unknown
-			}
-			continue
-		} else if isSpace(r) && isSpace(pr) {
-			// All space is collapsed into a single space, so if this char is
-			// a space and the previous was too, then skip the extra space.
-			if Debug {
-				fmt.Println("Skip space")
+			m.firstPar = 0
+		} else if m.params && m.listValue >= 0 {
+			// (1), (2) -> extend the list value to include (2)
+			m.values[m.listValue] = newValue(ValueList, m.q, m.values[m.listValue].Start, qi+1)
+		}
+		// ... the difficult part is that there may be other values, e.g.
+		// (1), (2), (3).  So we enter the following state.  The values list
+		// ends when the next char is not a comma.
+		m.s = moreValuesOrUnknown
+		m.pr = r
+		m.cpFromOffset = qi + 1
+		m.parOpenTotal = 0
+		return
+	} else if m.s == inMLC {
+		// We're in a /* mutli-line comments */.  Skip and ignore it all.
+		if m.pr == '*' && r == '/' {
+			// /* foo */ -> (nothing)
+			m.trace("Multi-line comment end")
+			m.s = unknown
+		} else {
+			m.trace("Ignore multi-line comment content")
+		}
+		m.pr = r // save previous rune so we can match */
+		return
+	} else if m.s == inHint {
+		// We're in /*+ optimizer hints */ which change the query plan, so
+		// they are kept, normalized, when they end.
+		if m.pr == '*' && r == '/' {
+			m.trace("Optimizer hint end")
+			m.copyBefore(m.openStart) // like SELECT in SELECT/*+ BKA(t1) */
+			m.appendSpace()
+			m.f = append(m.f, normalizeHint(m.query(m.openStart, qi+1), m.fp.SQLMode)...)
+			m.f = append(m.f, ' ')
+			m.cpFromOffset = qi + 1
+			m.cpToOffset = qi + 1
+			m.s = unknown
+			m.pr = ' ' // skip space after the hint like space after space
+			return
+		}
+		m.pr = r
+		return
+	} else if m.s == inVersion {
+		// We're in the NNNNN of /*!NNNNN MySQL-specific code */. The server
+		// executes the code if NNNNN is not greater than its version, else
+		// the code is a comment.
+		maxLen := 5
+		if m.mariaDB {
+			maxLen = 6 // /*M!100501
+		}
+		if r >= '0' && r <= '9' && m.versionLen < maxLen {
+			m.version = m.version*10 + int(r-'0')
+			m.versionLen++
+			return
+		}
+		if m.versionLen > 0 && m.version > m.fp.ServerVersion.number() {
+			m.trace("MySQL-specific code for newer version")
+			m.s = inMLC
+			m.pr = r
+			return
+		}
+		// /*!40001 SQL_NO_CACHE */ -> SQL_NO_CACHE, so the code begins here
+		// like after a space, and r is processed below.
+		m.trace("MySQL-specific code for this version")
+		m.inCode = true
+		if m.sqlState == inValues && (isSpace(r) || r == '(') {
+			// IN /*!40001 (1, 2) */ -> in(?+), VALUES (1), /*!40001 (2) */
+			// -> values(?+), so the values begin here.
+			m.s = inValues
+			m.cpFromOffset = qi + 1
+			if r == '(' {
+				m.parOpen = 1
+				m.firstPar = qi
 			}
-			// +1 here ensures we actually skip the extra space in certain
-			// cases like "select \n-- bar\n foo".  When a part of the query
-			// triggers a copy of preceding chars, if the only preceding char
-			// is a space then it's incorrectly copied, but +1 sets cpFromOffset
-			// to the same offset as the trigger char, thus avoiding the copy.
-			// For example in that ^ query, the offsets are:
-			//   0 's'
-			//   1 'e'
-			//   2 'l'
-			//   3 'e'
-			//   4 'c'
-			//   5 't'
-			//   6 ' '
-			//   7 '\n'
-			//   8 '-'
-			// After copying 'select ', we are here @ 7 and intend to skip the
-			// newline.  Next, the '-' @ 8 triggers a copy of any preceding
-			// chars.  So here if we set cpFromOffset = 7 then 7:8 is copied,
-			// the newline, but setting cpFromOffset = 7 + 1 is 8:8 and so
-			// nothing is copied as we want.  Actually, cpToOffset is still 6
-			// in this case, but 8:6 avoids the copy too.
-			cpFromOffset = qi + 1
-			pr = r
-			continue
-		}
-
-		/**
-		 * 2. Change state based on rune and current state.
-		 */
-
-		switch {
-		case r >= 0x30 && r <= 0x39: // 0-9
-			switch s {
-			case opOrNumber:
-				if Debug {
-					fmt.Println("+/-First digit")
-				}
-				cpToOffset = qi - 1
-				s = inNumber
-			case inOp:
-				if Debug {
-					fmt.Println("First digit after operator")
-				}
-				cpToOffset = qi
-				s = inNumber
-			case inWord:
-				if pr == '(' {
-					if Debug {
-						fmt.Println("Number in function")
-					}
-					cpToOffset = qi
-					s = inNumber
-				} else if pr == ',' {
-					// foo,4 -- 4 may be a number literal or a word/ident
-					if Debug {
-						fmt.Println("Number or word")
-					}
-					s = inNumber
-					cpToOffset = qi
-				} else {
-					if Debug {
-						fmt.Println("Number in word")
-					}
-					if ReplaceNumbersInWords {
-						s = inNumberInWord
-						cpToOffset = qi
-					}
-				}
-			default:
-				if Debug {
-					fmt.Println("Number literal")
-				}
-				s = inNumber
-				cpToOffset = qi
+			return
+		}
+		m.appendSpace()
+		m.cpFromOffset = qi
+		m.cpToOffset = qi
+		m.s = unknown
+		m.pr = ' '
+		if isSpace(r) {
+			m.cpFromOffset = qi + 1
+			return
+		}
+	} else if m.s == mlcOrMySQLCode || m.s == mlcOrMariaDBCode {
+		// We're at the start of either a /* multi-line comment */, some
+		// /*![version] some MySQL-specific code */, /*M![version] some
+		// MariaDB-specific code */, or /*+ optimizer hints */. The !, M!, or +
+		// after the /* determines which one. MariaDB-specific code is like
+		// MySQL-specific code, but only MariaDB executes it.
+		if m.s == mlcOrMySQLCode && r == '+' && !m.fp.StripHints {
+			m.trace("Optimizer hint")
+			m.s = inHint
+			return
+		} else if m.s == mlcOrMySQLCode && r == 'M' {
+			m.trace("Multi-line comment or MariaDB-specific code")
+			m.s = mlcOrMariaDBCode
+			return
+		} else if r != '!' {
+			m.trace("Multi-line comment")
+			m.s = inMLC
+			m.pr = r
+			return
+		} else if m.s == mlcOrMariaDBCode && m.fp.ServerVersion != (Version{}) && !m.fp.ServerVersion.MariaDB {
+			m.trace("MariaDB-specific code for MySQL")
+			m.s = inMLC
+			m.pr = r
+			return
+		} else if m.fp.ServerVersion != (Version{}) {
+			m.trace("MySQL-specific code version")
+			if m.sqlState != inValues {
+				m.copyBefore(m.openStart) // not the , in VALUES (1), /*!40001 (2) */
 			}
-		case isSpace(r):
-			if s == unknown {
-				if Debug {
-					fmt.Println("Lost in space")
-				}
-				if pr == '`' {
-					// Preserve space after `ident`, like "select `c` from t",
-					// and don't change cpToOffset because it's already set to
-					// the space after the closing backtick.
-					addSpace = true
-				} else if fi > 0 && (!isSpace(rune(f[fi-1])) && f[fi-1] != '.') {
-					if Debug {
-						fmt.Println("Add space")
-					}
-					f[fi] = ' '
-					fi++
-					// This is a common case: a space after skipping something,
-					// e.g. col = 'foo'<space>. We want only the first space,
-					// so advance cpFromOffset to whatever is after the space
-					// and if it's more space then space skipping block will
-					// handle it.
-					cpFromOffset = qi + 1
-				}
-			} else if s == inDash {
-				if Debug {
-					fmt.Println("One-line comment begin")
-				}
-				s = inOLC
-				if cpToOffset > 2 {
-					cpToOffset = qi - 2
-				}
-			} else if s == moreValuesOrUnknown {
-				if Debug {
-					fmt.Println("Space after values")
-				}
-				if valueNo == 1 {
-					f[fi] = ' '
-					fi++
-				}
+			m.mariaDB = m.s == mlcOrMariaDBCode
+			m.s = inVersion
+			m.version = 0
+			m.versionLen = 0
+			return
+		} else {
+			// /*![version] SQL_NO_CACHE */ -> /*![version] SQL_NO_CACHE */ (no change)
+			m.trace("MySQL-specific code")
+			m.s = inWord
+			m.sqlState = inMySQLCode
+		}
+	} else if m.s == inOLC {
+		// We're in a -- one line comment.  A space after -- is required.
+		// It ends at the end of the line, but there can be more query after
+		// it like:
+		//   SELECT * -- comment
+		//   FROM t
+		// is really "SELECT * FROM t".
+		if r == 0x0A { // newline
+			m.trace("One-line comment end")
+			m.s = unknown
+		}
+		return
+	} else if isSpace(r) && isSpace(m.pr) {
+		// All space is collapsed into a single space, so if this char is
+		// a space and the previous was too, then skip the extra space.
+		m.trace("Skip space")
+		// +1 here ensures we actually skip the extra space in certain
+		// cases like "select \n-- bar\n foo".  When a part of the query
+		// triggers a copy of preceding chars, if the only preceding char
+		// is a space then it's incorrectly copied, but +1 sets cpFromOffset
+		// to the same offset as the trigger char, thus avoiding the copy.
+		// For example in that ^ query, the offsets are:
+		//   0 's'
+		//   1 'e'
+		//   2 'l'
+		//   3 'e'
+		//   4 'c'
+		//   5 't'
+		//   6 ' '
+		//   7 '\n'
+		//   8 '-'
+		// After copying 'select ', we are here @ 7 and intend to skip the
+		// newline.  Next, the '-' @ 8 triggers a copy of any preceding
+		// chars.  So here if we set cpFromOffset = 7 then 7:8 is copied,
+		// the newline, but setting cpFromOffset = 7 + 1 is 8:8 and so
+		// nothing is copied as we want.  Actually, cpToOffset is still 6
+		// in this case, but 8:6 avoids the copy too.
+		m.cpFromOffset = qi + 1
+		m.pr = r
+		return
+	}
+
+	/**
+	 * 2. Change state based on rune and current state.
+	 */
+
+	switch {
+	case r >= 0x30 && r <= 0x39: // 0-9
+		switch m.s {
+		case opOrNumber:
+			m.trace("+/-First digit")
+			m.cpToOffset = qi - 1
+			m.s = inNumber
+			m.numStart = qi - 1
+		case inOp:
+			m.trace("First digit after operator")
+			m.cpToOffset = qi
+			m.s = inNumber
+			m.numStart = qi
+		case inWord:
+			if m.pr == '(' {
+				m.trace("Number in function")
+				m.cpToOffset = qi
+				m.s = inNumber
+				m.numStart = qi
+			} else if m.pr == ',' {
+				// foo,4 -- 4 may be a number literal or a word/ident
+				m.trace("Number or word")
+				m.s = inNumber
+				m.cpToOffset = qi
+				m.numStart = qi
 			} else {
-				if Debug {
-					fmt.Println("Word end")
-				}
-				word := strings.ToLower(q[cpFromOffset:qi])
-				// Only match USE if it is the first word in the query, otherwise,
-				// it could be a USE INDEX
-				if word == "use" && prevWord == "" {
-					return "use ?"
-				} else if (word == "null" && (prevWord != "is" && prevWord != "not")) || word == "null," {
-					if Debug {
-						fmt.Println("NULL as value")
-					}
-					f[fi] = '?'
-					fi++
-					if word[len(word)-1] == ',' {
-						f[fi] = ','
-						fi++
-					}
-					f[fi] = ' '
-					fi++
-					cpFromOffset = qi + 1
-				} else if prevWord == "order" && word == "by" {
-					if Debug {
-						fmt.Println("ORDER BY begin")
-					}
-					sqlState = orderBy
-				} else if sqlState == orderBy && wordIn(word, "asc", "asc,", "asc ") {
-					if Debug {
-						fmt.Println("ORDER BY ASC")
-					}
-					cpFromOffset = qi
-					if word[len(word)-1] == ',' {
-						fi--
-						f[fi] = ','
-						f[fi+1] = ' '
-						fi += 2
-					}
-				} else if prevWord == "key" && word == "update" {
-					if Debug {
-						fmt.Println("ON DUPLICATE KEY UPDATE begin")
-					}
-					sqlState = onDupeKeyUpdate
+				m.trace("Number in word")
+				if m.fp.ReplaceNumbersInWords {
+					m.s = inNumberInWord
+					m.cpToOffset = qi
 				}
-				s = inSpace
-				cpToOffset = qi
-				addSpace = true
-			}
-		case r == '\'' || r == '"':
-			if pr != '\\' {
-				if s != inQuote {
-					if Debug {
-						fmt.Println("Quote begin")
-					}
-					s = inQuote
-					quoteChar = r
-					cpToOffset = qi
-					if pr == 'x' || pr == 'b' {
-						if Debug {
-							fmt.Println("Hex/binary value")
-						}
-						// We're at the first quote char of x'0F'
-						// (or b'0101', etc.), so -2 for the quote char and
-						// the x or b char to copy anything before and up to
-						// this value.
-						cpToOffset = -2
-					}
-				}
-			}
-		case r == '`':
-			if pr != '\\' {
-				if s != inBackticks {
-					if Debug {
-						fmt.Println("Backticks begin")
-					}
-					s = inBackticks
-					quoteChar = r
-					cpToOffset = qi
-				}
-
 			}
-		case r == '=' || r == '<' || r == '>' || r == '!':
-			if Debug {
-				fmt.Println("Operator")
-			}
-			if s != inWord && s != inOp {
-				cpFromOffset = qi
-			}
-			s = inOp
-		case r == '/':
-			if Debug {
-				fmt.Println("Op or multi-line comment")
-			}
-			s = divOrMLC
-		case r == '*' && s == divOrMLC:
-			if Debug {
-				fmt.Println("Multi-line comment or MySQL-specific code")
-			}
-			s = mlcOrMySQLCode
-		case r == '+':
-			if Debug {
-				fmt.Println("Operator or number")
+		default:
+			m.trace("Number literal")
+			m.s = inNumber
+			m.cpToOffset = qi
+			m.numStart = qi
+		}
+	case isSpace(r):
+		if m.s == unknown {
+			m.trace("Lost in space")
+			if m.pr == '`' {
+				// Preserve space after `ident`, like "select `c` from t",
+				// and don't change cpToOffset because it's already set to
+				// the space after the closing backtick.
+				m.addSpace = true
+			} else if len(m.f) > 0 && (!isSpace(rune(m.f[len(m.f)-1])) && m.f[len(m.f)-1] != '.') {
+				m.trace("Add space")
+				m.f = append(m.f, ' ')
+				// This is a common case: a space after skipping something,
+				// e.g. col = 'foo'<space>. We want only the first space,
+				// so advance cpFromOffset to whatever is after the space
+				// and if it's more space then space skipping block will
+				// handle it.
+				m.cpFromOffset = qi + 1
 			}
-			s = opOrNumber
-		case r == '-':
-			if pr == '-' {
-				if Debug {
-					fmt.Println("Dash")
-				}
-				s = inDash
-			} else {
-				if Debug {
-					fmt.Println("Operator or number")
-				}
-				s = opOrNumber
+		} else if m.s == inDash {
+			m.trace("One-line comment begin")
+			m.s = inOLC
+			if m.cpToOffset > 2 {
+				m.cpToOffset = qi - 2
 			}
-		case r == '.':
-			if s == inNumber || s == inOp {
-				if Debug {
-					fmt.Println("Floating point number")
-				}
-				s = inNumber
-				cpToOffset = qi
-			} else {
-				cpToOffset = qi + 1
-				s = unknown
+		} else if m.s == moreValuesOrUnknown {
+			m.trace("Space after values")
+			if m.valueNo == 1 {
+				m.f = append(m.f, ' ')
 			}
-		case r == '(':
-			if prevWord == "call" {
-				// 'CALL foo(...)' -> 'call foo'
-				if Debug {
-					fmt.Println("CALL sp_name")
-				}
-				return "call " + q[cpFromOffset:qi]
-			} else if sqlState != onDupeKeyUpdate && (((s == inSpace || s == moreValuesOrUnknown) && (prevWord == "value" || prevWord == "values" || prevWord == "in")) || wordIn(q[cpFromOffset:qi], "value", "values", "in")) {
-				// VALUE(, VALUE (, VALUES(, VALUES (, IN(, or IN(
-				// but not after ON DUPLICATE KEY UPDATE
-				if Debug {
-					fmt.Println("Values begin")
+		} else {
+			m.trace("Word end")
+			word := strings.ToLower(m.query(m.cpFromOffset, qi))
+			// Only match USE if it is the first word in the query, otherwise,
+			// it could be a USE INDEX
+			if word == "use" && m.prevWord == "" {
+				m.result, m.done = "use ?", true
+				return
+			} else if (word == "null" && (m.prevWord != "is" && m.prevWord != "not")) || word == "null," {
+				m.trace("NULL as value")
+				m.f = append(m.f, '?')
+				if m.params {
+					m.values = append(m.values, newValue(ValueNull, m.q, m.cpFromOffset, m.cpFromOffset+4))
 				}
-				s = inValues
-				sqlState = inValues
-				parOpen = 1
-				firstPar = qi
-				if valueNo == 0 {
-					cpToOffset = qi
+				if word[len(word)-1] == ',' {
+					m.f = append(m.f, ',')
 				}
-			} else if s != inWord {
-				if Debug {
-					fmt.Println("Random (")
+				m.f = append(m.f, ' ')
+				m.cpFromOffset = qi + 1
+			} else if m.prevWord == "order" && word == "by" {
+				m.trace("ORDER BY begin")
+				m.sqlState = orderBy
+			} else if m.sqlState == orderBy && wordIn(word, "asc", "asc,", "asc ") {
+				m.trace("ORDER BY ASC")
+				m.cpFromOffset = qi
+				if word[len(word)-1] == ',' {
+					m.f[len(m.f)-1] = ','
+					m.f = append(m.f, ' ')
 				}
-				valueNo = 0
-				cpFromOffset = qi
-				s = inWord
+			} else if m.prevWord == "key" && word == "update" {
+				m.trace("ON DUPLICATE KEY UPDATE begin")
+				m.sqlState = onDupeKeyUpdate
 			}
-		case r == ',' && s == moreValuesOrUnknown:
-			if Debug {
-				fmt.Println("More values")
-			}
-		case r == ':' && prevWord == "administrator":
-			// 'administrator command: Init DB' -> 'administrator command: Init DB' (no change)
-			if Debug {
-				fmt.Println("Admin cmd")
-			}
-			return q[0 : len(q)-1] // original query minus the trailing space we added
-		case r == '#':
-			if Debug {
-				fmt.Println("One-line comment begin")
+			m.s = inSpace
+			m.cpToOffset = qi
+			m.addSpace = true
+		}
+	case r == '`' || (r == '"' && m.fp.SQLMode&ANSIQuotes != 0):
+		if m.pr != '\\' {
+			if m.s != inBackticks {
+				m.trace("Backticks begin")
+				m.s = inBackticks
+				m.quoteChar = r
+				m.cpToOffset = qi
+				m.openStart = qi
 			}
-			addSpace = false
-			s = inOLC
-		default:
-			if s != inWord && s != inOp {
-				// If in a word or operator then keep copying the query, else
-				// previous chars were being ignored for some reasons but now
-				// we should start copying again, so set cpFromOffset.  Example:
-				// col=NOW(). 'col' will be set to copy, but then '=' will put
-				// us in inOp state which, if a value follows, will trigger a
-				// copy of "col=", but "NOW()" is not a value so "N" is caught
-				// here and since s=inOp still we do not copy yet (this block is
-				// is not entered).
-				if Debug {
-					fmt.Println("Random character")
-				}
-				valueNo = 0
-				cpFromOffset = qi
-
-				if sqlState == inValues {
-					// Values are comma-separated, so the first random char
-					// marks the end of the VALUE() or IN() list.
-					if Debug {
-						fmt.Println("No more values")
+
+		}
+	case r == m.quoteChar && qi == m.quoteEnd && m.s == unknown:
+		// 'It''s': a doubled quote char is a literal quote char, so the
+		// quoted value did not end and its ? is removed.
+		m.trace("Doubled quote")
+		m.f = m.f[:len(m.f)-1]
+		if m.params {
+			m.values = m.values[:len(m.values)-1]
+		}
+		m.s = inQuote
+		return
+	case r == '\'' || r == '"':
+		if m.pr != '\\' {
+			if m.s != inQuote {
+				m.trace("Quote begin")
+				m.s = inQuote
+				m.quoteChar = r
+				m.cpToOffset = qi
+				m.quoteStart = qi
+				m.openStart = qi
+				m.quoteKind = ValueString
+				if m.pr == 'x' || m.pr == 'b' {
+					m.trace("Hex/binary value")
+					// We're at the first quote char of x'0F'
+					// (or b'0101', etc.), so -2 for the quote char and
+					// the x or b char to copy anything before and up to
+					// this value.
+					m.cpToOffset = -2
+					m.quoteStart = qi - 1
+					m.quoteKind = ValueHex
+					if m.pr == 'b' {
+						m.quoteKind = ValueBit
 					}
-					sqlState = unknown
 				}
 			}
-			s = inWord
 		}
-
-		/**
-		 * 3. Copy a slice of the query into the fingerprint.
-		 */
-
-		if cpToOffset > cpFromOffset {
-			l := cpToOffset - cpFromOffset
-			prevWord = strings.ToLower(q[cpFromOffset:cpToOffset])
-			if Debug {
-				fmt.Printf("copy '%s' (%d:%d, %d:%d) %d\n", prevWord, fi, fi+l, cpFromOffset, cpToOffset, l)
+	case r == '=' || r == '<' || r == '>' || r == '!':
+		m.trace("Operator")
+		if m.s != inWord && m.s != inOp {
+			m.cpFromOffset = qi
+		}
+		m.s = inOp
+	case r == '/' && m.pr == '*' && m.inCode:
+		// */ that ends /*! MySQL-specific code */ is like a space
+		m.trace("MySQL-specific code end")
+		m.inCode = false
+		m.copyBefore(qi - 1)
+		m.appendSpace()
+		m.cpFromOffset = qi + 1
+		m.cpToOffset = qi + 1
+		m.s = unknown
+		m.pr = ' '
+		return
+	case r == '/':
+		m.trace("Op or multi-line comment")
+		m.s = divOrMLC
+	case r == '*' && m.s == divOrMLC:
+		m.trace("Multi-line comment or MySQL-specific code")
+		m.s = mlcOrMySQLCode
+		m.openStart = qi - 1
+	case r == '+':
+		m.trace("Operator or number")
+		m.s = opOrNumber
+	case r == '-':
+		if m.pr == '-' {
+			m.trace("Dash")
+			m.s = inDash
+		} else {
+			m.trace("Operator or number")
+			m.s = opOrNumber
+		}
+	case r == '.':
+		if m.s == inNumber || m.s == inOp {
+			m.trace("Floating point number")
+			m.s = inNumber
+			m.cpToOffset = qi
+			m.numStart = qi
+		} else {
+			m.cpToOffset = qi + 1
+			m.s = unknown
+		}
+	case r == '(':
+		if m.prevWord == "call" && m.copies == 1 {
+			// 'CALL foo(...)' -> 'call foo'
+			m.trace("CALL sp_name")
+			m.result, m.done = "call "+m.query(m.cpFromOffset, qi), true
+			return
+		} else if m.sqlState != onDupeKeyUpdate && (((m.s == inSpace || m.s == moreValuesOrUnknown) && (m.prevWord == "value" || m.prevWord == "values" || m.prevWord == "in")) || wordIn(m.query(m.cpFromOffset, qi), "value", "values", "in")) {
+			// VALUE(, VALUE (, VALUES(, VALUES (, IN(, or IN(
+			// but not after ON DUPLICATE KEY UPDATE
+			m.trace("Values begin")
+			m.s = inValues
+			m.sqlState = inValues
+			m.parOpen = 1
+			m.firstPar = qi
+			if m.valueNo == 0 {
+				m.cpToOffset = qi
 			}
-			copy(f[fi:fi+l], prevWord)
-			fi += l
-			cpFromOffset = cpToOffset
-			if wordIn(prevWord, "in", "value", "values") && sqlState != onDupeKeyUpdate {
-				// IN ()     -> in(?+)
-				// VALUES () -> values(?+)
-				addSpace = false
-				s = inValues
-				sqlState = inValues
-			} else if addSpace {
-				if Debug {
-					fmt.Println("Add space")
-				}
-				f[fi] = ' '
-				fi++
-				cpFromOffset++
-				addSpace = false
+		} else if m.s != inWord {
+			m.trace("Random (")
+			m.valueNo = 0
+			m.cpFromOffset = qi
+			m.s = inWord
+		}
+	case r == ',' && m.s == moreValuesOrUnknown:
+		m.trace("More values")
+	case r == ':' && m.prevWord == "administrator" && m.copies == 1:
+		// 'administrator command: Init DB' -> 'administrator command: Init DB' (no change)
+		m.trace("Admin cmd")
+		m.admin, m.done = true, true
+		return
+	case r == '#':
+		m.trace("One-line comment begin")
+		m.addSpace = false
+		m.s = inOLC
+	default:
+		if m.s != inWord && m.s != inOp {
+			// If in a word or operator then keep copying the query, else
+			// previous chars were being ignored for some reasons but now
+			// we should start copying again, so set cpFromOffset.  Example:
+			// col=NOW(). 'col' will be set to copy, but then '=' will put
+			// us in inOp state which, if a value follows, will trigger a
+			// copy of "col=", but "NOW()" is not a value so "N" is caught
+			// here and since s=inOp still we do not copy yet (this block is
+			// is not entered).
+			m.trace("Random character")
+			m.valueNo = 0
+			m.cpFromOffset = qi
+
+			if m.sqlState == inValues {
+				// Values are comma-separated, so the first random char
+				// marks the end of the VALUE() or IN() list.
+				m.trace("No more values")
+				m.sqlState = unknown
 			}
 		}
-		pr = r
+		m.s = inWord
 	}
 
-	// Remove trailing spaces.
-	for fi > 0 && isSpace(rune(f[fi-1])) {
-		fi--
-	}
+	/**
+	 * 3. Copy a slice of the query into the fingerprint.
+	 */
 
-	// Clean up control characters, and return the fingerprint
-	return strings.Replace(string(f[0:fi]), "\x00", "", -1)
+	if m.cpToOffset > m.cpFromOffset {
+		l := m.cpToOffset - m.cpFromOffset
+		m.prevWord = strings.ToLower(m.query(m.cpFromOffset, m.cpToOffset))
+		if m.tracer != nil {
+			m.copied = m.prevWord
+		}
+		m.copyWord(l)
+		m.copies++
+		m.cpFromOffset = m.cpToOffset
+		if wordIn(m.prevWord, "in", "value", "values") && m.sqlState != onDupeKeyUpdate {
+			// IN ()     -> in(?+)
+			// VALUES () -> values(?+)
+			m.addSpace = false
+			m.s = inValues
+			m.sqlState = inValues
+		} else if m.addSpace {
+			m.trace("Add space")
+			m.f = append(m.f, ' ')
+			m.cpFromOffset++
+			m.addSpace = false
+		}
+	}
+	m.pr = r
 }
 
 func isSpace(r rune) bool {
@@ -759,8 +1061,10 @@ func wordIn(q string, words ...string) bool {
 // Id returns the right-most 16 characters of the MD5 checksum of fingerprint.
 // Query IDs are the shortest way to uniquely identify queries.
 func Id(fingerprint string) string {
-	id := md5.New()
-	io.WriteString(id, fingerprint)
-	h := fmt.Sprintf("%x", id.Sum(nil))
-	return strings.ToUpper(h[16:32])
+	return IdHashMD5.ID(fingerprint).String()
+}
+
+// ID returns the numeric ID of fingerprint computed with fp.IdHash.
+func (fp *Fingerprinter) ID(fingerprint string) ID {
+	return fp.IdHash.ID(fingerprint)
 }
@@ -12,7 +12,7 @@ import (
 )
 
 // Uncomment to check for 100% test coverage:
-//query.Debug = true
+//var fp = &query.Fingerprinter{Debug: true}
 
 func TestFingerprintBasic(t *testing.T) {
 	var q string
@@ -349,6 +349,171 @@ func TestFingerprintMultiLineComments(t *testing.T) {
 	}
 }
 
+func TestFingerprintHints(t *testing.T) {
+	var q string
+	var f string
+
+	// Hints are kept, normalized, because they change the query plan
+	q = "SELECT /*+ INDEX(t idx) MAX_EXECUTION_TIME(1000) */ * FROM t WHERE id IN (1,2)"
+	f = "select /*+ index(t idx) max_execution_time(?) */ * from t where id in(?+)"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	q = "SELECT/*+BKA(t1)*/c FROM t1"
+	f = "select /*+ bka(t1) */ c from t1"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	q = "INSERT /*+ SET_VAR(foreign_key_checks=OFF) */ INTO t VALUES (1),(2)"
+	f = "insert /*+ set_var(foreign_key_checks=off) */ into t values(?+)"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	q = "select /*+ QB_NAME(`qb 1`) */ 1"
+	f = "select /*+ qb_name(`qb 1`) */ ?"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// Backtick-quoted identifiers are lowercase like in the query
+	q = "SELECT /*+ QB_NAME(`QB1`) */ * FROM `T`"
+	f = "select /*+ qb_name(`qb1`) */ * from `t`"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// StripHints removes them like other comments
+	fp := &query.Fingerprinter{StripHints: true}
+	q = "SELECT /*+ BKA(t1) */ c FROM t1 WHERE id=1"
+	f = "select c from t1 where id=?"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+}
+
+func TestFingerprintServerVersion(t *testing.T) {
+	var q string
+	var f string
+
+	fp := &query.Fingerprinter{ServerVersion: query.Version{Major: 8, Minor: 0, Patch: 34}}
+
+	// Code for this version is part of the query
+	q = "SELECT /*!40001 SQL_NO_CACHE */ * FROM `t` WHERE id=1"
+	f = "select sql_no_cache * from `t` where id=?"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	q = "/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */"
+	f = "set @old_character_set_client=@@character_set_client"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	q = "CREATE TABLE t (a int) ENGINE=InnoDB /*!50100 PARTITION BY HASH (a) PARTITIONS 4 */"
+	f = "create table t (a int) engine=innodb partition by hash (a) partitions ?"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	q = "SELECT/*!STRAIGHT_JOIN*/c FROM t"
+	f = "select straight_join c from t"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// Code for a newer version is a comment
+	q = "SELECT /*!80035 SQL_NO_CACHE */ * FROM t /*!90001 WHERE id=1 */"
+	f = "select * from t"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// MySQL versions are 5 digits, so /*!100501 is version 10050 and code 1
+	q = "SELECT /*!100501 */"
+	f = "select ?"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// Code after IN or VALUES can be the values
+	q = "SELECT * FROM t WHERE a IN /*!40001 (1,2) */ AND b=1"
+	f = "select * from t where a in(?+) and b=?"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+	if _, values := fp.Parameterize(q); len(values) != 2 || values[0].Text != "(1,2)" {
+		t.Errorf("got values %v, expected (1,2) and 1", values)
+	}
+
+	q = "INSERT INTO t VALUES /*!40001 (1) */"
+	f = "insert into t values(?+)"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	q = "INSERT INTO t VALUES (1), /*!40001 (2) */"
+	f = "insert into t values(?+)"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// By default, the code is kept
+	q = "SELECT /*!40001 SQL_NO_CACHE */ * FROM t"
+	f = "select /*!40001 sql_no_cache */ * from t"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	q = "SELECT * FROM t WHERE a IN /*!40001 (1,2) */"
+	f = "select * from t where a in /*!40001 (?,?) */"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+}
+
+func TestFingerprintSQLMode(t *testing.T) {
+	var q string
+	var f string
+
+	// "col" is a string by default but an identifier with ANSI_QUOTES
+	q = `SELECT "Col" FROM "My Table" WHERE a="x" AND b='y'`
+	f = "select ? from ? where a=? and b=?"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+	fp := &query.Fingerprinter{SQLMode: query.ANSIQuotes}
+	f = `select "col" from "my table" where a="x" and b=?`
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// \ is not an escape char with NO_BACKSLASH_ESCAPES
+	fp = &query.Fingerprinter{SQLMode: query.NoBackslashEscapes}
+	q = `SELECT * FROM t WHERE path='C:\' AND id=1`
+	f = "select * from t where path=? and id=?"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// A doubled quote char is a literal quote char in every mode
+	q = "SELECT * FROM t WHERE name='It''s' AND id=1"
+	f = "select * from t where name=? and id=?"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+	_, values := fp.Parameterize(q)
+	if len(values) != 2 || values[0].Text != "'It''s'" {
+		t.Errorf("got values %v, expected 'It''s' and 1", values)
+	}
+}
+
 func TestFingerprintTricky(t *testing.T) {
 	var q string
 	var f string
@@ -419,19 +584,19 @@ func TestId(t *testing.T) {
 	f = "hello world"
 	id = "93CB22BB8F5ACDC3"
 	if got := query.Id(f); got != id {
-		t.Error("got %s \nexpected %d", got, id)
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, id)
 	}
 
 	f = "select sourcetable, if(f.lastcontent = ?, f.lastupdate, f.lastcontent) as lastactivity, f.totalcount as activity, type.class as type, (f.nodeoptions & ?) as nounsubscribe from node as f inner join contenttype as type on type.contenttypeid = f.contenttypeid inner join subscribed as sd on sd.did = f.nodeid and sd.userid = ? union all select f.name as title, f.userid as keyval, ? as sourcetable, ifnull(f.lastpost, f.joindate) as lastactivity, f.posts as activity, ? as type, ? as nounsubscribe from user as f inner join userlist as ul on ul.relationid = f.userid and ul.userid = ? where ul.type = ? and ul.aq = ? order by title limit ?"
 	id = "DB9EF18846547B8C"
 	if got := query.Id(f); got != id {
-		t.Error("got %s \nexpected %d", got, id)
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, id)
 	}
 
 	f = "select sleep(?) from n"
 	id = "7F7D57ACDD8A346E"
 	if got := query.Id(f); got != id {
-		t.Error("got %s \nexpected %d", got, id)
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, id)
 	}
 }
 
@@ -505,6 +670,91 @@ func TestFingerprintKeywords(t *testing.T) {
 	}
 }
 
+func TestFingerprintMariaDB(t *testing.T) {
+	var q string
+	var f string
+
+	// RETURNING
+	q = "INSERT INTO t (a) VALUES (1), (2) RETURNING id, a"
+	f = "insert into t (a) values(?+) returning id, a"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	q = "DELETE FROM t WHERE id IN (1, 2) RETURNING id"
+	f = "delete from t where id in(?+) returning id"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// Sequences: value in NEXT VALUE FOR is not VALUES
+	q = "SELECT NEXTVAL(seq1), LASTVAL(seq1), SETVAL(seq1, 100)"
+	f = "select nextval(seq1), lastval(seq1), setval(seq1, ?)"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	q = "SELECT NEXT VALUE FOR seq1, PREVIOUS VALUE FOR db1.seq1 FROM t WHERE id=1"
+	f = "select next value for seq1, previous value for db1.seq1 from t where id=?"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// System-versioned tables
+	q = "SELECT * FROM t FOR SYSTEM_TIME AS OF TIMESTAMP '2016-10-09 08:07:06'"
+	f = "select * from t for system_time as of timestamp ?"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// Lock wait options
+	q = "SELECT * FROM t WHERE id=1 FOR UPDATE WAIT 5"
+	f = "select * from t where id=? for update wait ?"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	q = "ALTER TABLE t NOWAIT ADD COLUMN c INT"
+	f = "alter table t nowait add column c int"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// MariaDB-specific code is kept like MySQL-specific code by default, and
+	// evaluated only for MariaDB if ServerVersion is set
+	q = "CREATE /*M! OR REPLACE */ TABLE t (a int) /*M!100300 WITH SYSTEM VERSIONING */"
+	f = "create /*m! or replace */ table t (a int) /*m!100300 with system versioning */"
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	fp := &query.Fingerprinter{ServerVersion: query.Version{Major: 10, Minor: 6, MariaDB: true}}
+	f = "create or replace table t (a int) with system versioning"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	fp = &query.Fingerprinter{ServerVersion: query.Version{Major: 10, Minor: 2, MariaDB: true}}
+	f = "create or replace table t (a int)"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	fp = &query.Fingerprinter{ServerVersion: query.Version{Major: 8}}
+	f = "create table t (a int)"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// MariaDB versions are 6 digits
+	fp = &query.Fingerprinter{ServerVersion: query.Version{Major: 10, Minor: 6, MariaDB: true}}
+	q = "SELECT * FROM t WHERE a IN /*M!100501 (1,2) */"
+	f = "select * from t where a in(?+)"
+	if got := fp.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+}
+
 func TestFingerprintUseIndex(t *testing.T) {
 	var q string
 	var f string
@@ -520,41 +770,36 @@ func TestFingerprintWithNumberInDbName(t *testing.T) {
 	var q string
 	var f string
 
-	defaultReplaceNumbersInWords := query.ReplaceNumbersInWords
-	query.ReplaceNumbersInWords = true
-	defer func() {
-		// Restore default value for other tests
-		query.ReplaceNumbersInWords = defaultReplaceNumbersInWords
-	}()
+	fp := &query.Fingerprinter{ReplaceNumbersInWords: true}
 
 	q = "SELECT c FROM org235.t WHERE id=0xdeadbeaf"
 	f = "select c from org?.t where id=?"
-	if got := query.Fingerprint(q); got != f {
+	if got := fp.Fingerprint(q); got != f {
 		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
 	}
 
 	q = "CREATE DATABASE org235_percona345 COLLATE 'utf8_general_ci'"
 	f = "create database org?_percona? collate ?"
-	if got := query.Fingerprint(q); got != f {
+	if got := fp.Fingerprint(q); got != f {
 		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
 	}
 
 	q = "select foo_1 from foo_2_3"
 	f = "select foo_? from foo_?_?"
-	if got := query.Fingerprint(q); got != f {
+	if got := fp.Fingerprint(q); got != f {
 		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
 	}
 
 	q = "SELECT * FROM prices.rt_5min where id=1"
 	f = "select * from prices.rt_?min where id=?"
-	if got := query.Fingerprint(q); got != f {
+	if got := fp.Fingerprint(q); got != f {
 		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
 	}
 
 	// Prefixes are not supported, requires more hacks
 	q = "select 123foo from 123foo"
 	f = "select 123foo from 123foo"
-	if got := query.Fingerprint(q); got != f {
+	if got := fp.Fingerprint(q); got != f {
 		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
 	}
 }
@@ -569,3 +814,248 @@ func TestBackticks(t *testing.T) {
 		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
 	}
 }
+
+func TestFingerprinterConcurrent(t *testing.T) {
+	// Different configurations must not interfere with each other.
+	q := "SELECT c FROM org235.t WHERE id=1"
+	plain := &query.Fingerprinter{}
+	words := &query.Fingerprinter{ReplaceNumbersInWords: true}
+
+	done := make(chan bool)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for j := 0; j < 100; j++ {
+				if got := plain.Fingerprint(q); got != "select c from org235.t where id=?" {
+					t.Errorf("plain got: %s", got)
+				}
+				if got := words.Fingerprint(q); got != "select c from org?.t where id=?" {
+					t.Errorf("words got: %s", got)
+				}
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+
+	if got := query.Fingerprint(q); got != plain.Fingerprint(q) {
+		t.Errorf("Fingerprint got %s, expected same as zero Fingerprinter", got)
+	}
+}
+
+func TestParameterize(t *testing.T) {
+	q := "select 0x0, x'123', 0b1010, b'10101', null from foo where a = -5 and b='x' and c in (1, 2) limit 10"
+	f := "select ?, ?, ?, ?, ? from foo where a = ? and b=? and c in(?+) limit ?"
+	expect := []query.Value{
+		{Kind: query.ValueHex, Text: "0x0", Start: 7, End: 10},
+		{Kind: query.ValueHex, Text: "x'123'", Start: 12, End: 18},
+		{Kind: query.ValueBit, Text: "0b1010", Start: 20, End: 26},
+		{Kind: query.ValueBit, Text: "b'10101'", Start: 28, End: 36},
+		{Kind: query.ValueNull, Text: "null", Start: 38, End: 42},
+		{Kind: query.ValueNumber, Text: "-5", Start: 62, End: 64},
+		{Kind: query.ValueString, Text: "'x'", Start: 71, End: 74},
+		{Kind: query.ValueList, Text: "(1, 2)", Start: 84, End: 90},
+		{Kind: query.ValueNumber, Text: "10", Start: 97, End: 99},
+	}
+	got, values := query.Parameterize(q)
+	if got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+	if len(values) != len(expect) {
+		t.Fatalf("got %d values, expected %d: %+v", len(values), len(expect), values)
+	}
+	for i := range expect {
+		if values[i] != expect[i] {
+			t.Errorf("value %d: got %+v, expected %+v", i, values[i], expect[i])
+		}
+		if q[values[i].Start:values[i].End] != values[i].Text {
+			t.Errorf("value %d: offsets %d:%d do not match text %s", i, values[i].Start, values[i].End, values[i].Text)
+		}
+	}
+
+	// Multiple () are one list value.
+	q = "insert into foo(a, b) values(2, 'a') , (3,'b')"
+	f = "insert into foo(a, b) values(?+)"
+	got, values = query.Parameterize(q)
+	if got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+	if len(values) != 1 || values[0].Kind != query.ValueList || values[0].Text != "(2, 'a') , (3,'b')" {
+		t.Errorf("got %+v, expected one list value", values)
+	}
+
+	// Empty value list is not replaced, so no values.
+	got, values = query.Parameterize("INSERT INTO t () VALUES ()")
+	if got != "insert into t () values()" || len(values) != 0 {
+		t.Errorf("got %s %+v, expected no values", got, values)
+	}
+
+	// Fingerprint and Parameterize return the same fingerprint.
+	q = "SELECT c FROM t WHERE 1=1 AND id=0xdeadbeaf"
+	if got, _ := query.Parameterize(q); got != query.Fingerprint(q) {
+		t.Errorf("got %s, expected %s", got, query.Fingerprint(q))
+	}
+}
+
+// fingerprintTests are queries and their fingerprints with the default
+// Fingerprinter. They cover how values, words, quoted values, comments, and
+// MySQL-specific code are scanned, so TestLexerParity uses them to check the
+// Lexer against Fingerprint.
+var fingerprintTests = []struct {
+	q string
+	f string
+}{
+	{
+		"SELECT c FROM t WHERE id=1",
+		"select c from t where id=?",
+	},
+	{
+		"select null, 5.001, 5001. from foo",
+		"select ?, ?, ? from foo",
+	},
+	{
+		"select 0e0, +6e-30, -6.00 from foo where a = 5.5 or b=0.5 or c=.5",
+		"select ?, ?, ? from foo where a = ? or b=? or c=?",
+	},
+	{
+		"select 0x0, x'123', 0b1010, b'10101' from foo",
+		"select ?, ?, ?, ? from foo",
+	},
+	{
+		"select 12ff, 12ffz, 0x1F, 0xfg, 1e-9, 1-2 from t",
+		"select ?, 12ffz, ?, 0xfg, ?, ? from t",
+	},
+	{
+		"select foo_1, 123foo, db23.t1 from foo_2_3",
+		"select foo_1, 123foo, db23.t1 from foo_2_3",
+	},
+	{
+		"SELECT * FROM prices.rt_5min where id=1",
+		"select * from prices.rt_5min where id=?",
+	},
+	{
+		"SELECT c FROM org235.t WHERE id=0xdeadbeaf",
+		"select c from org235.t where id=?",
+	},
+	{
+		"select 'hello', '\nhello\n', \"hello\", '\\'' from foo",
+		"select ?, ?, ?, ? from foo",
+	},
+	{
+		"select '\\\\' from foo",
+		"select ? from foo",
+	},
+	{
+		"SELECT 'It''s', \"say \"\"hi\"\"\" FROM t",
+		"select ?, ? from t",
+	},
+	{
+		"SELECT '' '' '' FROM kamil",
+		"select ? ? ? from kamil",
+	},
+	{
+		"SELECT * FROM t WHERE a = -1 AND b = - 2",
+		"select * from t where a = ? and b = - ?",
+	},
+	{
+		"select * from foo where a in (5) and b in (5, 8,9 ,9 , 10)",
+		"select * from foo where a in(?+) and b in(?+)",
+	},
+	{
+		"insert into foo values (1, '(2)', 'This is a trick: ). More values.', 4)",
+		"insert into foo values(?+)",
+	},
+	{
+		"insert into foo(a, b, c) values(2, 4, 5) , (2,4,5)",
+		"insert into foo(a, b, c) values(?+)",
+	},
+	{
+		"select * from foo limit 5, 10",
+		"select * from foo limit ?, ?",
+	},
+	{
+		"select \n-- bar\n foo",
+		"select foo",
+	},
+	{
+		"select foo-- bar\n,foo",
+		"select foo,foo",
+	},
+	{
+		"select a --1 from t",
+		"select a --? from t",
+	},
+	{
+		"select a # comment\nfrom t",
+		"select a from t",
+	},
+	{
+		"/* criteria query */ select linked_at from t where id=1 /* trailing */",
+		"select linked_at from t where id=?",
+	},
+	{
+		"SELECT /*!40001 SQL_NO_CACHE */ * FROM `film`",
+		"select /*!40001 sql_no_cache */ * from `film`",
+	},
+	{
+		"SELECT * FROM t WHERE a IN /*!40001 (1,2) */",
+		"select * from t where a in /*!40001 (?,?) */",
+	},
+	{
+		"CREATE /*M! OR REPLACE */ TABLE t (a int) /*M!100300 WITH SYSTEM VERSIONING */",
+		"create /*m! or replace */ table t (a int) /*m!100300 with system versioning */",
+	},
+	{
+		"SELECT /*+ INDEX(t idx) MAX_EXECUTION_TIME(1000) */ * FROM t WHERE id IN (1,2)",
+		"select /*+ index(t idx) max_execution_time(?) */ * from t where id in(?+)",
+	},
+	{
+		"SELECT/*+BKA(t1)*/c FROM t1",
+		"select /*+ bka(t1) */ c from t1",
+	},
+	{
+		"select `col` from `table-1` where `id` = 5",
+		"select `col` from `table-1` where `id` = ?",
+	},
+	{
+		"select `a``b`, `1` from `db-2`.`t 3` where x=1",
+		"select `a``b`, `1` from `db-2`.`t 3` where x=?",
+	},
+	{
+		"select field from `-master-db-1`.`-table-1-` order by id, ?;",
+		"select field from `-master-db-1`.`-table-1-` order by id, ?;",
+	},
+	{
+		"SELECT * FROM t WHERE a <=> 1 AND b != 2 AND @v := 3",
+		"select * from t where a <=> ? and b != ? and @v := ?",
+	},
+	{
+		"SELECT @a, @@session.sql_mode, @`x` FROM t WHERE id = @b",
+		"select @a, @@session.sql_mode, @`x` from t where id = @b",
+	},
+	{
+		"SELECT _utf8mb4'abc', N'def', 1.5 FROM t",
+		"select _utf8mb4?, n?, ? from t",
+	},
+	{
+		"select sleep(2) from test.n",
+		"select sleep(?) from test.n",
+	},
+	{
+		"INSERT INTO t (ts) VALUES ('()', '\\(', '\\)')",
+		"insert into t (ts) values(?+)",
+	},
+	{
+		"SELECT * FROM t FOR SYSTEM_TIME AS OF TIMESTAMP '2016-10-09 08:07:06'",
+		"select * from t for system_time as of timestamp ?",
+	},
+}
+
+func TestFingerprintTests(t *testing.T) {
+	for _, test := range fingerprintTests {
+		if got := query.Fingerprint(test.q); got != test.f {
+			t.Errorf("%s\ngot:\n%s\nexpected:\n%s\n", test.q, got, test.f)
+		}
+	}
+}
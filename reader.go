@@ -0,0 +1,152 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"unicode/utf8"
+)
+
+// readSize is the minimum number of bytes FingerprintReader reads at once.
+const readSize = 32 * 1024
+
+// FingerprintReader is like Fingerprint but it reads the query from r and
+// writes the fingerprint to w. See Fingerprinter.FingerprintReader.
+func FingerprintReader(r io.Reader, w io.Writer) error {
+	return defaultFingerprinter.FingerprintReader(r, w)
+}
+
+// FingerprintReader fingerprints the query read from r and writes the
+// fingerprint to w. The fingerprint is identical to Fingerprint, but the query
+// is not read into memory: only the part that can still be copied into the
+// fingerprint is kept, so huge quoted values and value lists, like in a
+// multi-megabyte bulk INSERT, use no more memory than a short query. The
+// fingerprint is written as it is made, except for administrator commands
+// which are written unchanged when the whole query has been read. With
+// DialectPostgreSQL or ProxySQL, the whole query is read into memory.
+func (fp *Fingerprinter) FingerprintReader(r io.Reader, w io.Writer) error {
+	if fp.Dialect == DialectPostgreSQL || fp.ProxySQL != nil {
+		q, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, fp.Fingerprint(string(q)))
+		return err
+	}
+	m := newMachine(fp, false)
+	buf := make([]byte, readSize)
+	qi := 0 // offset of the next rune
+	eof := false
+	for !eof {
+		m.trimWindow(qi)
+
+		// Read at least as much as the window to copy it in linear time.
+		if len(m.q) > len(buf) {
+			buf = make([]byte, len(m.q))
+		}
+		n, err := r.Read(buf)
+		if err == io.EOF {
+			eof = true
+		} else if err != nil {
+			return err
+		}
+		m.q += string(buf[:n])
+		if eof {
+			m.q += " " // need range to run off end of original query
+		}
+
+		for qi-m.base < len(m.q) {
+			rest := m.q[qi-m.base:]
+			if !eof && !utf8.FullRuneInString(rest) {
+				break // read the rest of the rune
+			}
+			c, size := utf8.DecodeRuneInString(rest)
+			m.step(qi, c)
+			if m.done {
+				return m.writeDone(r, w, eof)
+			}
+			qi += size
+		}
+
+		if err := m.flush(w); err != nil {
+			return err
+		}
+	}
+
+	m.trimSpace()
+	return writeFingerprint(w, m.f)
+}
+
+// trimWindow discards the query before offset qi that the machine cannot read
+// anymore. That is everything before cpFromOffset, or everything in a quoted
+// value or value list because cpFromOffset is set after it. An administrator
+// command is returned unchanged, so it is kept whole.
+func (m *machine) trimWindow(qi int) {
+	keep := qi
+	switch {
+	case m.copies == 1 && m.prevWord == "administrator":
+		keep = 0
+	case m.s == inQuote, m.s == inValues && m.parOpen > 0:
+		// keep only qi
+	case m.cpFromOffset < keep:
+		keep = m.cpFromOffset
+	}
+	if keep > m.base {
+		m.q = m.q[keep-m.base:]
+		m.base = keep
+	}
+}
+
+// flush writes the part of f that cannot change. Nothing is written until
+// there are two copies because USE, CALL, and administrator commands replace
+// the fingerprint. The last byte and trailing spaces are kept because they
+// can be changed or removed.
+func (m *machine) flush(w io.Writer) error {
+	if m.copies < 2 {
+		return nil
+	}
+	n := len(m.f) - 1
+	for n > 0 && isSpace(rune(m.f[n-1])) {
+		n--
+	}
+	if n <= 0 {
+		return nil
+	}
+	if err := writeFingerprint(w, m.f[:n]); err != nil {
+		return err
+	}
+	m.fo += n
+	m.f = m.f[:copy(m.f, m.f[n:])]
+	return nil
+}
+
+// writeDone writes the fingerprint of a USE, CALL, or administrator command.
+func (m *machine) writeDone(r io.Reader, w io.Writer, eof bool) error {
+	if !m.admin {
+		_, err := io.WriteString(w, m.result)
+		return err
+	}
+	// The window begins at offset 0, see trimWindow.
+	q := m.q
+	if eof {
+		q = q[0 : len(q)-1] // minus the trailing space we added
+	}
+	if _, err := io.WriteString(w, q); err != nil {
+		return err
+	}
+	if eof {
+		return nil
+	}
+	_, err := io.Copy(w, r)
+	return err
+}
+
+// writeFingerprint writes f without control characters.
+func writeFingerprint(w io.Writer, f []byte) error {
+	_, err := w.Write(bytes.Replace(f, []byte{0}, nil, -1))
+	return err
+}
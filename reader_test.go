@@ -0,0 +1,86 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/go-mysql/query"
+)
+
+func TestFingerprintReader(t *testing.T) {
+	queries := []string{
+		"SELECT c FROM t WHERE id=1",
+		"select * from a join b on a.id=b.id where b.name='It\\'s' and a.c in (1, 2, 3) order by a.c asc, b.d asc limit 5",
+		"INSERT INTO t (a, b) VALUES (1, 'a'), (2, 'b') ON DUPLICATE KEY UPDATE a=VALUES(a)",
+		"select /* c */ `col-1`, x'0F', b'01', -1.5e-9 from `db`.`t` -- c2\n# c3\nwhere d is not null",
+		"/*!40101 SET NAMES utf8 */",
+		"SELECT c FROM org235.t WHERE id=0xdeadbeaf",
+		"select 'a', \"b\", null, NULL, 1 from dual",
+		"select İK, 'é' from té where \xe2\x82 = 1",
+		"use `db`",
+		"CALL foo(1, 2, 3)",
+		"administrator command: Init DB",
+		"",
+		"   ",
+	}
+	for _, q := range queries {
+		f := query.Fingerprint(q)
+		var got bytes.Buffer
+		if err := query.FingerprintReader(strings.NewReader(q), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != f {
+			t.Errorf("got:\n%s\nexpected:\n%s\n", got.String(), f)
+		}
+
+		// One byte at a time, to split runes and everything else.
+		got.Reset()
+		if err := query.FingerprintReader(iotest.OneByteReader(strings.NewReader(q)), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != f {
+			t.Errorf("got:\n%s\nexpected:\n%s\n", got.String(), f)
+		}
+	}
+}
+
+func TestFingerprintReaderLarge(t *testing.T) {
+	q := "INSERT INTO t (a, b) VALUES " +
+		strings.Repeat("(1, 'It''s a \\' ) trick'), ", 100000) +
+		"(2, 'x') ON DUPLICATE KEY UPDATE a=1"
+	f := "insert into t (a, b) values(?+) on duplicate key update a=?"
+	var got bytes.Buffer
+	if err := query.FingerprintReader(strings.NewReader(q), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got.String(), f)
+	}
+	if got := query.Fingerprint(q); got != f {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, f)
+	}
+
+	// The rest of an administrator command is copied unchanged.
+	q = "administrator command: " + strings.Repeat("x", 100000)
+	got.Reset()
+	if err := query.FingerprintReader(strings.NewReader(q), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != q {
+		t.Errorf("got %d bytes, expected %d", got.Len(), len(q))
+	}
+}
+
+func TestFingerprintReaderError(t *testing.T) {
+	r := iotest.TimeoutReader(strings.NewReader("SELECT c FROM t WHERE id=1"))
+	var got bytes.Buffer
+	if err := query.FingerprintReader(iotest.OneByteReader(r), &got); err != iotest.ErrTimeout {
+		t.Errorf("got error %v, expected %v", err, iotest.ErrTimeout)
+	}
+}
@@ -0,0 +1,430 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+// Package slowlog parses MySQL slow logs.
+package slowlog
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql/query"
+)
+
+// An Event is one query in a slow log. Times are in seconds.
+//
+// Fields after RowsExamined are written only by Percona Server and MariaDB,
+// depending on log_slow_verbosity, so they are zero if not in the slow log.
+// Attributes that are not fields, like those written by newer versions, are
+// saved in Extra: "# Bytes_received: 10" is Extra["Bytes_received"] = "10".
+type Event struct {
+	Offset       uint64    // byte offset of the event in the slow log
+	Ts           time.Time // from # Time, or SET timestamp if no # Time
+	User         string
+	Host         string // hostname, or IP if no hostname
+	Db           string // from use db; or Schema
+	ThreadId     uint64 // from Id or Thread_id
+	QueryTime    float64
+	LockTime     float64
+	RowsSent     uint64
+	RowsExamined uint64
+	Query        string // without use db; and SET timestamp=N;
+	Fingerprint  string // query.Fingerprint(Query)
+	Id           string // query.Id(Fingerprint), or Parser.Fingerprinter.ID
+
+	// Percona Server and MariaDB
+	LastErrno      uint64
+	Killed         uint64
+	RowsAffected   uint64
+	BytesSent      uint64
+	TmpTables      uint64
+	TmpDiskTables  uint64
+	TmpTableSizes  uint64
+	QCHit          bool
+	FullScan       bool
+	FullJoin       bool
+	TmpTable       bool
+	TmpTableOnDisk bool
+	Filesort       bool
+	FilesortOnDisk bool
+	MergePasses    uint64
+	PriorityQueue  bool
+
+	// InnoDB, Percona Server only
+	InnoDBTrxId         string
+	InnoDBIOReadOps     uint64
+	InnoDBIOReadBytes   uint64
+	InnoDBIOReadWait    float64
+	InnoDBRecLockWait   float64
+	InnoDBQueueWait     float64
+	InnoDBPagesDistinct uint64
+
+	// Rate limiting, Percona Server only
+	LogSlowRateType  string
+	LogSlowRateLimit uint64
+
+	Extra map[string]string // unknown attributes
+}
+
+// Metrics returns the numeric attributes of e by their slow log names, like
+// "Query_time", for query.Aggregator. Attributes written only by Percona
+// Server and MariaDB are included if not zero.
+func (e *Event) Metrics() map[string]float64 {
+	m := map[string]float64{
+		"Query_time":    e.QueryTime,
+		"Lock_time":     e.LockTime,
+		"Rows_sent":     float64(e.RowsSent),
+		"Rows_examined": float64(e.RowsExamined),
+	}
+	opt := map[string]float64{
+		"Rows_affected":         float64(e.RowsAffected),
+		"Bytes_sent":            float64(e.BytesSent),
+		"Tmp_tables":            float64(e.TmpTables),
+		"Tmp_disk_tables":       float64(e.TmpDiskTables),
+		"Tmp_table_sizes":       float64(e.TmpTableSizes),
+		"Merge_passes":          float64(e.MergePasses),
+		"InnoDB_IO_r_ops":       float64(e.InnoDBIOReadOps),
+		"InnoDB_IO_r_bytes":     float64(e.InnoDBIOReadBytes),
+		"InnoDB_IO_r_wait":      e.InnoDBIOReadWait,
+		"InnoDB_rec_lock_wait":  e.InnoDBRecLockWait,
+		"InnoDB_queue_wait":     e.InnoDBQueueWait,
+		"InnoDB_pages_distinct": float64(e.InnoDBPagesDistinct),
+	}
+	for k, v := range opt {
+		if v != 0 {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// A Parser reads events from a slow log. It is not safe for concurrent use.
+type Parser struct {
+	// Fingerprinter fingerprints queries, and its ID method computes Event.Id.
+	// If nil, query.Fingerprint and query.Id are used.
+	Fingerprinter *query.Fingerprinter
+
+	r          *bufio.Reader
+	offset     uint64 // of next line read from r
+	line       string // first line of next event if already read
+	lineOffset uint64
+	hasLine    bool
+	err        error // error from r, returned after the last event
+}
+
+// NewParser returns a Parser that reads the slow log from r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{
+		r: bufio.NewReader(r),
+	}
+}
+
+// Next returns the next event in the slow log. It returns io.EOF after the
+// last event. Queries can span multiple lines. Header lines that MySQL
+// writes when it starts are skipped.
+func (p *Parser) Next() (*Event, error) {
+	var e *Event
+	var queryLines []string
+	inQuery := false // query lines have been read
+	ts := time.Time{}
+	useDb := ""
+
+	for {
+		line, offset, ok := p.readLine()
+		if !ok {
+			break
+		}
+		if isServerHeader(line) {
+			if inQuery {
+				break
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "# ") {
+			newEvent := strings.HasPrefix(line, "# Time:") || strings.HasPrefix(line, "# User@Host:")
+			if inQuery && newEvent {
+				p.unreadLine(line, offset)
+				break
+			}
+			if !inQuery {
+				if e == nil {
+					e = &Event{Offset: offset}
+				}
+				if strings.HasPrefix(line, "# administrator command: ") {
+					// Admin commands are logged in place of the query.
+					queryLines = append(queryLines, line[2:])
+					inQuery = true
+					continue
+				}
+				parseHeader(e, line)
+				continue
+			}
+		}
+
+		if e == nil {
+			e = &Event{Offset: offset}
+		}
+		if !inQuery {
+			// SET timestamp=N; and use db; precede the query.
+			lower := strings.ToLower(line)
+			if strings.HasPrefix(lower, "set timestamp=") {
+				n, _ := strconv.ParseInt(strings.TrimRight(line[len("set timestamp="):], "; \t\r"), 10, 64)
+				ts = time.Unix(n, 0).UTC()
+				continue
+			}
+			if strings.HasPrefix(lower, "use ") {
+				useDb = strings.Trim(strings.TrimRight(line[4:], "; \t\r"), "`")
+				continue
+			}
+		}
+		queryLines = append(queryLines, line)
+		inQuery = true
+	}
+
+	if e == nil {
+		if p.err != nil && p.err != io.EOF {
+			return nil, p.err
+		}
+		return nil, io.EOF
+	}
+	if useDb != "" {
+		e.Db = useDb
+		if len(queryLines) == 0 {
+			queryLines = append(queryLines, "use "+useDb)
+		}
+	}
+	if e.Ts.IsZero() {
+		e.Ts = ts
+	}
+	e.Query = strings.TrimRight(strings.Join(queryLines, "\n"), "; \t\r\n")
+	fp := p.Fingerprinter
+	if fp == nil {
+		e.Fingerprint = query.Fingerprint(e.Query)
+		e.Id = query.Id(e.Fingerprint)
+	} else {
+		e.Fingerprint = fp.Fingerprint(e.Query)
+		e.Id = fp.ID(e.Fingerprint).String()
+	}
+	return e, nil
+}
+
+// readLine returns the next line without the newline, and its offset.
+func (p *Parser) readLine() (string, uint64, bool) {
+	if p.hasLine {
+		p.hasLine = false
+		return p.line, p.lineOffset, true
+	}
+	if p.err != nil {
+		return "", 0, false
+	}
+	line, err := p.r.ReadString('\n')
+	if err != nil {
+		p.err = err
+		if line == "" {
+			return "", 0, false
+		}
+	}
+	offset := p.offset
+	p.offset += uint64(len(line))
+	return strings.TrimRight(line, "\r\n"), offset, true
+}
+
+// unreadLine saves the line that begins the next event.
+func (p *Parser) unreadLine(line string, offset uint64) {
+	p.line = line
+	p.lineOffset = offset
+	p.hasLine = true
+}
+
+// isServerHeader returns true for the lines that MySQL writes at the start
+// of a slow log when it starts or flushes logs:
+//
+//	/usr/sbin/mysqld, Version: 5.7.20-log (MySQL Community Server (GPL)). started with:
+//	Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock
+//	Time                 Id Command    Argument
+func isServerHeader(line string) bool {
+	return (strings.Contains(line, ", Version: ") && strings.HasSuffix(line, "started with:")) ||
+		strings.HasPrefix(line, "Tcp port: ") ||
+		(strings.HasPrefix(line, "Time ") && strings.Contains(line, " Id Command"))
+}
+
+// parseHeader parses a # header line into e.
+func parseHeader(e *Event, line string) {
+	switch {
+	case strings.HasPrefix(line, "# Time:"):
+		e.Ts = parseTime(strings.TrimSpace(line[len("# Time:"):]))
+	case strings.HasPrefix(line, "# User@Host:"):
+		parseUserHost(e, line[len("# User@Host:"):])
+	default:
+		for key, val := range parseMetrics(line[2:]) {
+			if !setAttribute(e, key, val) {
+				if e.Extra == nil {
+					e.Extra = map[string]string{}
+				}
+				e.Extra[key] = val
+			}
+		}
+	}
+}
+
+// setAttribute sets the Event field for the slow log attribute key, or
+// returns false if there is no field. Keys are not case-sensitive because
+// Percona Server and MariaDB differ, like QC_Hit and QC_hit.
+func setAttribute(e *Event, key, val string) bool {
+	switch strings.ToLower(key) {
+	case "query_time":
+		e.QueryTime = parseFloat(val)
+	case "lock_time":
+		e.LockTime = parseFloat(val)
+	case "rows_sent":
+		e.RowsSent = parseUint(val)
+	case "rows_examined":
+		e.RowsExamined = parseUint(val)
+	case "thread_id":
+		e.ThreadId = parseUint(val)
+	case "schema":
+		if e.Db == "" {
+			e.Db = val
+		}
+	case "last_errno", "errno":
+		e.LastErrno = parseUint(val)
+	case "killed":
+		e.Killed = parseUint(val)
+	case "rows_affected":
+		e.RowsAffected = parseUint(val)
+	case "bytes_sent":
+		e.BytesSent = parseUint(val)
+	case "tmp_tables":
+		e.TmpTables = parseUint(val)
+	case "tmp_disk_tables":
+		e.TmpDiskTables = parseUint(val)
+	case "tmp_table_sizes":
+		e.TmpTableSizes = parseUint(val)
+	case "qc_hit":
+		e.QCHit = parseBool(val)
+	case "full_scan":
+		e.FullScan = parseBool(val)
+	case "full_join":
+		e.FullJoin = parseBool(val)
+	case "tmp_table":
+		e.TmpTable = parseBool(val)
+	case "tmp_table_on_disk":
+		e.TmpTableOnDisk = parseBool(val)
+	case "filesort":
+		e.Filesort = parseBool(val)
+	case "filesort_on_disk":
+		e.FilesortOnDisk = parseBool(val)
+	case "merge_passes":
+		e.MergePasses = parseUint(val)
+	case "priority_queue":
+		e.PriorityQueue = parseBool(val)
+	case "innodb_trx_id":
+		e.InnoDBTrxId = val
+	case "innodb_io_r_ops":
+		e.InnoDBIOReadOps = parseUint(val)
+	case "innodb_io_r_bytes":
+		e.InnoDBIOReadBytes = parseUint(val)
+	case "innodb_io_r_wait":
+		e.InnoDBIOReadWait = parseFloat(val)
+	case "innodb_rec_lock_wait":
+		e.InnoDBRecLockWait = parseFloat(val)
+	case "innodb_queue_wait":
+		e.InnoDBQueueWait = parseFloat(val)
+	case "innodb_pages_distinct":
+		e.InnoDBPagesDistinct = parseUint(val)
+	case "log_slow_rate_type":
+		e.LogSlowRateType = val
+	case "log_slow_rate_limit":
+		e.LogSlowRateLimit = parseUint(val)
+	default:
+		return false
+	}
+	return true
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+func parseUint(s string) uint64 {
+	n, _ := strconv.ParseUint(s, 10, 64)
+	return n
+}
+
+func parseBool(s string) bool {
+	return strings.EqualFold(s, "yes")
+}
+
+// parseTime parses the # Time value: 2017-01-01T12:00:00.123456Z since MySQL
+// 5.7, or 170101 12:00:00 (or 170101  9:00:00) before. The latter has no time
+// zone, so it is returned as UTC.
+func parseTime(s string) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t
+	}
+	f := strings.Fields(s)
+	if len(f) == 2 {
+		if len(f[1]) == 7 {
+			f[1] = "0" + f[1] // 9:00:00 -> 09:00:00
+		}
+		if t, err := time.Parse("060102 15:04:05", f[0]+" "+f[1]); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseUserHost parses " root[root] @ localhost [127.0.0.1]  Id:  3".
+func parseUserHost(e *Event, s string) {
+	at := strings.Index(s, " @ ")
+	if at < 0 {
+		return
+	}
+	user := strings.TrimSpace(s[:at])
+	if i := strings.Index(user, "["); i >= 0 {
+		user = user[:i]
+	}
+	e.User = user
+
+	host := s[at+3:]
+	if i := strings.Index(host, "  Id:"); i >= 0 {
+		e.ThreadId = parseUint(strings.TrimSpace(host[i+len("  Id:"):]))
+		host = host[:i]
+	}
+	host = strings.TrimSpace(host)
+	ip := ""
+	if i := strings.Index(host, "["); i >= 0 {
+		ip = strings.Trim(host[i:], "[] ")
+		host = strings.TrimSpace(host[:i])
+	}
+	if host == "" {
+		host = ip
+	}
+	e.Host = host
+}
+
+// parseMetrics parses "Key: value  Key: value ..." into a map. A key can have
+// an empty value, like "Schema:  Last_errno: 0".
+func parseMetrics(s string) map[string]string {
+	m := map[string]string{}
+	f := strings.Fields(s)
+	for i := 0; i < len(f); i++ {
+		if !strings.HasSuffix(f[i], ":") {
+			continue
+		}
+		key := strings.TrimSuffix(f[i], ":")
+		val := ""
+		if i+1 < len(f) && !strings.HasSuffix(f[i+1], ":") {
+			val = f[i+1]
+			i++
+		}
+		m[key] = val
+	}
+	return m
+}
@@ -0,0 +1,231 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package slowlog_test
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-mysql/query"
+	"github.com/go-mysql/query/slowlog"
+)
+
+func parseFile(t *testing.T, file string) []slowlog.Event {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return parse(t, f)
+}
+
+func parse(t *testing.T, r io.Reader) []slowlog.Event {
+	events := []slowlog.Event{}
+	p := slowlog.NewParser(r)
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, *e)
+	}
+	return events
+}
+
+func TestParserMySQL57(t *testing.T) {
+	got := parseFile(t, "testdata/mysql57.log")
+	expect := []slowlog.Event{
+		{
+			Offset:       185,
+			Ts:           time.Date(2017, 11, 5, 20, 21, 15, 123456000, time.UTC),
+			User:         "root",
+			Host:         "localhost",
+			Db:           "test",
+			ThreadId:     3,
+			QueryTime:    1.000123,
+			LockTime:     0.000045,
+			RowsSent:     1,
+			RowsExamined: 1000,
+			Query:        "SELECT c FROM t WHERE id=1",
+			Fingerprint:  "select c from t where id=?",
+			Id:           query.Id("select c from t where id=?"),
+		},
+		{
+			Offset:       413,
+			Ts:           time.Date(2017, 11, 5, 20, 21, 16, 1000, time.UTC),
+			User:         "app",
+			Host:         "10.0.0.5",
+			ThreadId:     4,
+			QueryTime:    0.5,
+			LockTime:     0.00001,
+			RowsExamined: 3,
+			Query:        "UPDATE t\n   SET c = 'foo'\n WHERE id IN (1, 2, 3)",
+			Fingerprint:  "update t set c = ? where id in(?+)",
+			Id:           query.Id("update t set c = ? where id in(?+)"),
+		},
+		{
+			Offset:      647,
+			Ts:          time.Unix(1509913277, 0).UTC(),
+			User:        "app",
+			Host:        "10.0.0.5",
+			ThreadId:    4,
+			QueryTime:   0.00001,
+			Query:       "administrator command: Quit",
+			Fingerprint: "administrator command: Quit",
+			Id:          query.Id("administrator command: Quit"),
+		},
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got:\n%#v\nexpected:\n%#v\n", got, expect)
+	}
+}
+
+func TestParserMySQL51(t *testing.T) {
+	got := parseFile(t, "testdata/mysql51.log")
+	if len(got) != 2 {
+		t.Fatalf("got %d events, expected 2: %#v", len(got), got)
+	}
+	if ts := time.Date(2007, 10, 15, 21, 43, 52, 0, time.UTC); !got[0].Ts.Equal(ts) {
+		t.Errorf("got Ts %s, expected %s", got[0].Ts, ts)
+	}
+	if ts := time.Date(2007, 10, 15, 9, 45, 10, 0, time.UTC); !got[1].Ts.Equal(ts) {
+		t.Errorf("got Ts %s, expected %s", got[1].Ts, ts)
+	}
+	if got[0].Db != "db1" || got[0].QueryTime != 2 || got[0].RowsSent != 1 {
+		t.Errorf("got %#v", got[0])
+	}
+	// Last query has no trailing ; or newline.
+	if got[1].Query != "select sleep(2) from n" || got[1].Fingerprint != "select sleep(?) from n" {
+		t.Errorf("got %#v", got[1])
+	}
+}
+
+func TestParserEmpty(t *testing.T) {
+	if got := parse(t, strings.NewReader("")); len(got) != 0 {
+		t.Errorf("got %#v, expected no events", got)
+	}
+}
+
+func TestParserFingerprinter(t *testing.T) {
+	log := "# Query_time: 1  Lock_time: 0  Rows_sent: 1  Rows_examined: 0\nSELECT c FROM org235.t;\n"
+	p := slowlog.NewParser(strings.NewReader(log))
+	p.Fingerprinter = &query.Fingerprinter{ReplaceNumbersInWords: true}
+	e, err := p.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Fingerprint != "select c from org?.t" {
+		t.Errorf("got %s, expected select c from org?.t", e.Fingerprint)
+	}
+	if e.Id != query.Id(e.Fingerprint) {
+		t.Errorf("got Id %s, expected %s", e.Id, query.Id(e.Fingerprint))
+	}
+
+	// The Id is computed with the Fingerprinter IdHash.
+	p = slowlog.NewParser(strings.NewReader(log))
+	p.Fingerprinter = &query.Fingerprinter{IdHash: query.IdHashSHA256}
+	e, err = p.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := query.IdHashSHA256.ID(e.Fingerprint).String(); e.Id != expect {
+		t.Errorf("got Id %s, expected %s", e.Id, expect)
+	}
+}
+
+func TestParserPerconaServer(t *testing.T) {
+	got := parseFile(t, "testdata/percona.log")
+	expect := []slowlog.Event{
+		{
+			Offset:              0,
+			Ts:                  time.Date(2017, 11, 5, 20, 21, 15, 0, time.UTC),
+			User:                "root",
+			Host:                "localhost",
+			Db:                  "imdb",
+			ThreadId:            23,
+			QueryTime:           0.5,
+			LockTime:            0.0001,
+			RowsSent:            5,
+			RowsExamined:        5000,
+			Query:               "SELECT * FROM title WHERE kind_id=3 ORDER BY year",
+			Fingerprint:         "select * from title where kind_id=? order by year",
+			Id:                  query.Id("select * from title where kind_id=? order by year"),
+			RowsAffected:        0,
+			BytesSent:           1024,
+			TmpTables:           1,
+			TmpDiskTables:       1,
+			TmpTableSizes:       16384,
+			QCHit:               false,
+			FullScan:            true,
+			FullJoin:            false,
+			TmpTable:            true,
+			TmpTableOnDisk:      true,
+			Filesort:            true,
+			FilesortOnDisk:      false,
+			MergePasses:         2,
+			InnoDBTrxId:         "1A2B3C",
+			InnoDBIOReadOps:     12,
+			InnoDBIOReadBytes:   196608,
+			InnoDBIOReadWait:    0.0123,
+			InnoDBRecLockWait:   0.5,
+			InnoDBQueueWait:     0.25,
+			InnoDBPagesDistinct: 8,
+			LogSlowRateType:     "query",
+			LogSlowRateLimit:    10,
+			Extra:               map[string]string{"Future_attr": "42"},
+		},
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got:\n%#v\nexpected:\n%#v\n", got, expect)
+	}
+}
+
+func TestParserMariaDB(t *testing.T) {
+	got := parseFile(t, "testdata/mariadb.log")
+	if len(got) != 1 {
+		t.Fatalf("got %d events, expected 1: %#v", len(got), got)
+	}
+	e := got[0]
+	if e.ThreadId != 42 || e.Db != "shop" || !e.QCHit || e.RowsAffected != 2 || e.BytesSent != 52 {
+		t.Errorf("got %#v", e)
+	}
+	if !e.FullScan || e.FullJoin || !e.Filesort || e.MergePasses != 1 || !e.PriorityQueue {
+		t.Errorf("got %#v", e)
+	}
+	if e.Fingerprint != "update orders set status=? where id=?" {
+		t.Errorf("got %s", e.Fingerprint)
+	}
+}
+
+func TestEventMetrics(t *testing.T) {
+	got := parseFile(t, "testdata/percona.log")[0].Metrics()
+	expect := map[string]float64{
+		"Query_time":            0.5,
+		"Lock_time":             0.0001,
+		"Rows_sent":             5,
+		"Rows_examined":         5000,
+		"Bytes_sent":            1024,
+		"Tmp_tables":            1,
+		"Tmp_disk_tables":       1,
+		"Tmp_table_sizes":       16384,
+		"Merge_passes":          2,
+		"InnoDB_IO_r_ops":       12,
+		"InnoDB_IO_r_bytes":     196608,
+		"InnoDB_IO_r_wait":      0.0123,
+		"InnoDB_rec_lock_wait":  0.5,
+		"InnoDB_queue_wait":     0.25,
+		"InnoDB_pages_distinct": 8,
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got:\n%v\nexpected:\n%v\n", got, expect)
+	}
+}
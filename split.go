@@ -0,0 +1,117 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"strings"
+)
+
+// A Statement is one statement in a multi-statement query or script, as
+// returned by Split. Text is the statement without its delimiter and the
+// space around it. Start and End are its offsets in the query: Text is
+// q[Start:End].
+type Statement struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// Split splits q into statements on ; outside quoted values, quoted
+// identifiers, and comments. Like the mysql client, it honors the DELIMITER
+// directive at the start of a statement: "DELIMITER //" makes // the delimiter
+// until the next DELIMITER. The directives are not returned, and neither are
+// empty statements or statements that are only comments. A comment before a
+// statement is part of the statement. The last statement does not need a
+// delimiter.
+func Split(q string) []Statement {
+	stmts := []Statement{}
+	delim := ";"
+	l := NewLexer(q)
+	start := -1   // offset of the current statement, or -1 if none
+	code := false // current statement is not only comments
+	for l.Next() {
+		t := l.Token()
+		switch t.Type {
+		case TokenSpace:
+			continue
+		case TokenString, TokenIdent, TokenComment, TokenHint:
+			if start < 0 {
+				start = t.Start
+			}
+			if t.Type == TokenString || t.Type == TokenIdent {
+				code = true
+			}
+			continue
+		}
+
+		if !code && t.Type == TokenWord && strings.EqualFold(t.Text, "delimiter") {
+			if d, next := delimiterArg(q, t.End); d != "" {
+				delim = d
+				l.pos = next
+				start = -1
+				continue
+			}
+		}
+		if start < 0 {
+			start = t.Start
+		}
+
+		// The delimiter can begin inside a token, like $$ in END$$, and span
+		// tokens, like //.
+		end := t.End + len(delim) - 1
+		if end > len(q) {
+			end = len(q)
+		}
+		i := strings.Index(q[t.Start:end], delim)
+		if i < 0 {
+			code = true
+			continue
+		}
+		if i > 0 {
+			code = true
+		}
+		stmts = appendStatement(stmts, q, start, t.Start+i, code)
+		l.pos = t.Start + i + len(delim)
+		start = -1
+		code = false
+	}
+	return appendStatement(stmts, q, start, len(q), code)
+}
+
+// delimiterArg returns the argument of the DELIMITER directive that ends at
+// offset pos in q, and the offset of the line after the directive. The
+// argument is empty if there is none.
+func delimiterArg(q string, pos int) (string, int) {
+	if pos >= len(q) || (q[pos] != ' ' && q[pos] != '\t') {
+		return "", pos // DELIMITER; or DELIMITERx
+	}
+	for pos < len(q) && (q[pos] == ' ' || q[pos] == '\t') {
+		pos++
+	}
+	start := pos
+	for pos < len(q) && !isSpace(rune(q[pos])) {
+		pos++
+	}
+	arg := q[start:pos]
+	for pos < len(q) && q[pos] != '\n' {
+		pos++
+	}
+	if pos < len(q) {
+		pos++ // newline
+	}
+	return arg, pos
+}
+
+func appendStatement(stmts []Statement, q string, start, end int, code bool) []Statement {
+	if start < 0 || !code {
+		return stmts
+	}
+	text := strings.TrimRight(q[start:end], " \t\r\n")
+	return append(stmts, Statement{
+		Text:  text,
+		Start: start,
+		End:   start + len(text),
+	})
+}
@@ -0,0 +1,90 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+// splitString returns the statements separated by | for easy comparison.
+func splitString(stmts []query.Statement) string {
+	s := []string{}
+	for _, stmt := range stmts {
+		s = append(s, stmt.Text)
+	}
+	return strings.Join(s, "|")
+}
+
+func TestSplit(t *testing.T) {
+	var q string
+	var expect string
+
+	q = "SELECT 1; SELECT 2;\nSELECT 3"
+	expect = "SELECT 1|SELECT 2|SELECT 3"
+	if got := splitString(query.Split(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+
+	// ; in quoted values, identifiers, and comments
+	q = "INSERT INTO t VALUES ('a;b', \"c;d\", 'it\\';s'); SELECT `x;y` FROM t -- e;f\n; /* g;h */ SELECT 2 # i;j"
+	expect = "INSERT INTO t VALUES ('a;b', \"c;d\", 'it\\';s')|SELECT `x;y` FROM t -- e;f|/* g;h */ SELECT 2 # i;j"
+	if got := splitString(query.Split(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+
+	// Empty and comment-only statements are ignored
+	q = ";; SELECT 1;  ; -- the end\n"
+	expect = "SELECT 1"
+	if got := splitString(query.Split(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+	if got := query.Split(""); len(got) != 0 {
+		t.Errorf("got %+v, expected no statements", got)
+	}
+}
+
+func TestSplitDelimiter(t *testing.T) {
+	q := `DROP PROCEDURE IF EXISTS p;
+DELIMITER //
+CREATE PROCEDURE p()
+BEGIN
+  SELECT 1;
+  SELECT 2;
+END//
+delimiter $$
+CREATE FUNCTION f() RETURNS INT RETURN 1$$
+DELIMITER ;
+CALL p();`
+	expect := "DROP PROCEDURE IF EXISTS p|" +
+		"CREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND|" +
+		"CREATE FUNCTION f() RETURNS INT RETURN 1|" +
+		"CALL p()"
+	if got := splitString(query.Split(q)); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+}
+
+func TestSplitOffsets(t *testing.T) {
+	q := "  SELECT 'a;' ;\n\t/* c */ UPDATE t SET c=1  "
+	got := query.Split(q)
+	expect := []query.Statement{
+		{Text: "SELECT 'a;'", Start: 2, End: 13},
+		{Text: "/* c */ UPDATE t SET c=1", Start: 17, End: 41},
+	}
+	if len(got) != len(expect) {
+		t.Fatalf("got %+v, expected %+v", got, expect)
+	}
+	for i := range expect {
+		if got[i] != expect[i] {
+			t.Errorf("got:\n%+v\nexpected:\n%+v\n", got[i], expect[i])
+		}
+		if q[got[i].Start:got[i].End] != got[i].Text {
+			t.Errorf("q[%d:%d] != %s", got[i].Start, got[i].End, got[i].Text)
+		}
+	}
+}
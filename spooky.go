@@ -0,0 +1,213 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"encoding/binary"
+)
+
+// SpookyHash V2 by Bob Jenkins (public domain), only Hash64 which ProxySQL
+// uses for query digests. Words are read little-endian like on x86.
+
+const (
+	spookyNumVars   = 12
+	spookyBlockSize = spookyNumVars * 8 // 96
+	spookyBufSize   = 2 * spookyBlockSize
+	spookyConst     = 0xdeadbeefdeadbeef
+)
+
+// spookyHash64 returns the 64-bit SpookyHash V2 of data.
+func spookyHash64(data []byte, seed uint64) uint64 {
+	h1, _ := spookyHash128(data, seed, seed)
+	return h1
+}
+
+func spookyHash128(data []byte, seed1, seed2 uint64) (uint64, uint64) {
+	if len(data) < spookyBufSize {
+		return spookyShort(data, seed1, seed2)
+	}
+
+	var h [spookyNumVars]uint64
+	h[0], h[3], h[6], h[9] = seed1, seed1, seed1, seed1
+	h[1], h[4], h[7], h[10] = seed2, seed2, seed2, seed2
+	h[2], h[5], h[8], h[11] = spookyConst, spookyConst, spookyConst, spookyConst
+
+	var block [spookyNumVars]uint64
+	for len(data) >= spookyBlockSize {
+		for i := range block {
+			block[i] = binary.LittleEndian.Uint64(data[i*8:])
+		}
+		spookyMix(&block, &h)
+		data = data[spookyBlockSize:]
+	}
+
+	// The last partial block is padded with zeros and its length.
+	var buf [spookyBlockSize]byte
+	copy(buf[:], data)
+	buf[spookyBlockSize-1] = byte(len(data))
+	for i := range block {
+		block[i] = binary.LittleEndian.Uint64(buf[i*8:])
+	}
+	for i := range h {
+		h[i] += block[i]
+	}
+	spookyEndPartial(&h)
+	spookyEndPartial(&h)
+	spookyEndPartial(&h)
+	return h[0], h[1]
+}
+
+func rot64(x uint64, k uint) uint64 {
+	return (x << k) | (x >> (64 - k))
+}
+
+func spookyMix(data, s *[spookyNumVars]uint64) {
+	rots := [spookyNumVars]uint{11, 32, 43, 31, 17, 28, 39, 57, 55, 54, 22, 46}
+	for i := 0; i < spookyNumVars; i++ {
+		s[i] += data[i]
+		s[(i+2)%spookyNumVars] ^= s[(i+10)%spookyNumVars]
+		s[(i+11)%spookyNumVars] ^= s[i]
+		s[i] = rot64(s[i], rots[i])
+		s[(i+11)%spookyNumVars] += s[(i+1)%spookyNumVars]
+	}
+}
+
+func spookyEndPartial(h *[spookyNumVars]uint64) {
+	rots := [spookyNumVars]uint{44, 15, 34, 21, 38, 33, 10, 13, 38, 53, 42, 54}
+	for i := 0; i < spookyNumVars; i++ {
+		h[(i+11)%spookyNumVars] += h[(i+1)%spookyNumVars]
+		h[(i+2)%spookyNumVars] ^= h[(i+11)%spookyNumVars]
+		h[(i+1)%spookyNumVars] = rot64(h[(i+1)%spookyNumVars], rots[i])
+	}
+}
+
+func spookyShort(data []byte, seed1, seed2 uint64) (uint64, uint64) {
+	length := len(data)
+	a, b := seed1, seed2
+	c, d := uint64(spookyConst), uint64(spookyConst)
+
+	if length > 15 {
+		for len(data) >= 32 {
+			c += binary.LittleEndian.Uint64(data)
+			d += binary.LittleEndian.Uint64(data[8:])
+			a, b, c, d = spookyShortMix(a, b, c, d)
+			a += binary.LittleEndian.Uint64(data[16:])
+			b += binary.LittleEndian.Uint64(data[24:])
+			data = data[32:]
+		}
+		if len(data) >= 16 {
+			c += binary.LittleEndian.Uint64(data)
+			d += binary.LittleEndian.Uint64(data[8:])
+			a, b, c, d = spookyShortMix(a, b, c, d)
+			data = data[16:]
+		}
+	}
+
+	// The last 0-15 bytes
+	d += uint64(length) << 56
+	switch n := len(data); {
+	case n >= 12:
+		for i := n - 1; i >= 12; i-- {
+			d += uint64(data[i]) << (8 * uint(i-8))
+		}
+		d += uint64(binary.LittleEndian.Uint32(data[8:]))
+		c += binary.LittleEndian.Uint64(data)
+	case n >= 8:
+		for i := n - 1; i >= 8; i-- {
+			d += uint64(data[i]) << (8 * uint(i-8))
+		}
+		c += binary.LittleEndian.Uint64(data)
+	case n >= 4:
+		for i := n - 1; i >= 4; i-- {
+			c += uint64(data[i]) << (8 * uint(i))
+		}
+		c += uint64(binary.LittleEndian.Uint32(data))
+	case n >= 1:
+		for i := n - 1; i >= 0; i-- {
+			c += uint64(data[i]) << (8 * uint(i))
+		}
+	default:
+		c += spookyConst
+		d += spookyConst
+	}
+	return spookyShortEnd(a, b, c, d)
+}
+
+func spookyShortMix(h0, h1, h2, h3 uint64) (uint64, uint64, uint64, uint64) {
+	h2 = rot64(h2, 50)
+	h2 += h3
+	h0 ^= h2
+	h3 = rot64(h3, 52)
+	h3 += h0
+	h1 ^= h3
+	h0 = rot64(h0, 30)
+	h0 += h1
+	h2 ^= h0
+	h1 = rot64(h1, 41)
+	h1 += h2
+	h3 ^= h1
+	h2 = rot64(h2, 54)
+	h2 += h3
+	h0 ^= h2
+	h3 = rot64(h3, 48)
+	h3 += h0
+	h1 ^= h3
+	h0 = rot64(h0, 38)
+	h0 += h1
+	h2 ^= h0
+	h1 = rot64(h1, 37)
+	h1 += h2
+	h3 ^= h1
+	h2 = rot64(h2, 62)
+	h2 += h3
+	h0 ^= h2
+	h3 = rot64(h3, 34)
+	h3 += h0
+	h1 ^= h3
+	h0 = rot64(h0, 5)
+	h0 += h1
+	h2 ^= h0
+	h1 = rot64(h1, 36)
+	h1 += h2
+	h3 ^= h1
+	return h0, h1, h2, h3
+}
+
+func spookyShortEnd(h0, h1, h2, h3 uint64) (uint64, uint64) {
+	h3 ^= h2
+	h2 = rot64(h2, 15)
+	h3 += h2
+	h0 ^= h3
+	h3 = rot64(h3, 52)
+	h0 += h3
+	h1 ^= h0
+	h0 = rot64(h0, 26)
+	h1 += h0
+	h2 ^= h1
+	h1 = rot64(h1, 51)
+	h2 += h1
+	h3 ^= h2
+	h2 = rot64(h2, 28)
+	h3 += h2
+	h0 ^= h3
+	h3 = rot64(h3, 9)
+	h0 += h3
+	h1 ^= h0
+	h0 = rot64(h0, 47)
+	h1 += h0
+	h2 ^= h1
+	h1 = rot64(h1, 54)
+	h2 += h1
+	h3 ^= h2
+	h2 = rot64(h2, 32)
+	h3 += h2
+	h0 ^= h3
+	h3 = rot64(h3, 25)
+	h0 += h3
+	h1 ^= h0
+	h0 = rot64(h0, 63)
+	h1 += h0
+	return h0, h1
+}
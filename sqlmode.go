@@ -0,0 +1,50 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"strings"
+)
+
+// A SQLMode is the server sql_mode values that change how queries are parsed.
+// Other sql_mode values do not change fingerprints, so they are not defined.
+// Values are combined with |, like ANSIQuotes | NoBackslashEscapes.
+type SQLMode uint
+
+const (
+	// ANSIQuotes makes "..." an identifier like `...`, not a string.
+	ANSIQuotes SQLMode = 1 << iota
+
+	// NoBackslashEscapes makes \ an ordinary char in strings, so 'C:\'
+	// is a complete string. A quote char is still escaped by doubling it.
+	NoBackslashEscapes
+)
+
+// ParseSQLMode parses a server sql_mode like "ANSI_QUOTES,NO_BACKSLASH_ESCAPES",
+// as returned by SELECT @@sql_mode. Combination modes that include ANSI_QUOTES,
+// like ANSI, are expanded, and modes that do not change parsing are ignored.
+func ParseSQLMode(s string) SQLMode {
+	var mode SQLMode
+	for _, m := range strings.Split(strings.ToUpper(s), ",") {
+		switch strings.TrimSpace(m) {
+		case "ANSI_QUOTES", "ANSI", "DB2", "MAXDB", "MSSQL", "ORACLE", "POSTGRESQL":
+			mode |= ANSIQuotes
+		case "NO_BACKSLASH_ESCAPES":
+			mode |= NoBackslashEscapes
+		}
+	}
+	return mode
+}
+
+func (m SQLMode) String() string {
+	modes := []string{}
+	if m&ANSIQuotes != 0 {
+		modes = append(modes, "ANSI_QUOTES")
+	}
+	if m&NoBackslashEscapes != 0 {
+		modes = append(modes, "NO_BACKSLASH_ESCAPES")
+	}
+	return strings.Join(modes, ",")
+}
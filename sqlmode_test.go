@@ -0,0 +1,31 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestParseSQLMode(t *testing.T) {
+	expect := map[string]query.SQLMode{
+		"":                                    0,
+		"STRICT_TRANS_TABLES,NO_ZERO_IN_DATE": 0,
+		"ANSI_QUOTES":                         query.ANSIQuotes,
+		"ansi":                                query.ANSIQuotes,
+		"NO_BACKSLASH_ESCAPES,ONLY_FULL_GROUP_BY": query.NoBackslashEscapes,
+		"ANSI_QUOTES, NO_BACKSLASH_ESCAPES":       query.ANSIQuotes | query.NoBackslashEscapes,
+	}
+	for s, mode := range expect {
+		if got := query.ParseSQLMode(s); got != mode {
+			t.Errorf("%s: got %s, expected %s", s, got, mode)
+		}
+	}
+
+	if got, expect := (query.ANSIQuotes | query.NoBackslashEscapes).String(), "ANSI_QUOTES,NO_BACKSLASH_ESCAPES"; got != expect {
+		t.Errorf("got %s, expected %s", got, expect)
+	}
+}
@@ -0,0 +1,115 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A SyntaxError is malformed input returned by FingerprintStrict. Offset is
+// the byte offset in the query where the unterminated part begins, like the
+// opening quote, and State is the name of the state that the fingerprint
+// state machine ended in, like "inQuote". With DialectPostgreSQL or ProxySQL,
+// or after USE, CALL, or an administrator command, State is the type of the
+// unterminated token, like "string", or "list".
+type SyntaxError struct {
+	Offset int
+	State  string
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d (state %s)", e.Msg, e.Offset, e.State)
+}
+
+// FingerprintStrict is like Fingerprint but returns a *SyntaxError if q is
+// malformed: an unterminated quoted value, backtick-quoted identifier,
+// /* comment */, or /*! MySQL-specific code */, or unbalanced parentheses
+// in a VALUES or IN list. These are usually queries that were truncated, like
+// by a slow log or max_allowed_packet. The fingerprint is returned with the
+// error; it is the same as Fingerprint returns, which is probably not the
+// fingerprint of the query that was truncated.
+//
+// FingerprintStrict does not validate SQL syntax: most invalid queries are
+// fingerprinted without error.
+func FingerprintStrict(q string) (string, error) {
+	return defaultFingerprinter.FingerprintStrict(q)
+}
+
+// FingerprintStrict is like Fingerprint but returns a *SyntaxError if q is
+// malformed. See the package-level FingerprintStrict.
+func (fp *Fingerprinter) FingerprintStrict(q string) (string, error) {
+	if fp.ProxySQL != nil {
+		d := fp.fingerprintProxySQL(q, false)
+		if d.err != nil {
+			return d.text, d.err
+		}
+		return d.text, nil
+	}
+	if fp.Dialect == DialectPostgreSQL {
+		p := fp.fingerprintPostgres(q, false)
+		if p.err != nil {
+			return string(p.f), p.err
+		}
+		return string(p.f), nil
+	}
+	m, f, _ := fp.run(q, false)
+	if m.done {
+		// USE, CALL, or administrator command fingerprinted by the first
+		// word(s), so the state machine did not scan the rest.
+		l := NewLexer(q)
+		l.SQLMode = fp.SQLMode
+		for l.Next() {
+		}
+		if err := l.syntaxError(); err != nil {
+			return f, err
+		}
+		return f, nil
+	}
+	if err := m.syntaxError(); err != nil {
+		return f, err
+	}
+	if strings.Contains(q, "/*!") || strings.Contains(q, "/*M!") {
+		// The state machine does not track the end of MySQL-specific code.
+		l := NewLexer(q)
+		l.SQLMode = fp.SQLMode
+		start := 0
+		for l.Next() {
+			if l.tok.Type == TokenMySQLCode {
+				start = l.tok.Start
+			}
+		}
+		if l.inCode {
+			return f, &SyntaxError{Offset: start, State: stateName[m.s], Msg: "unterminated MySQL-specific code"}
+		}
+	}
+	return f, nil
+}
+
+// syntaxError returns a *SyntaxError if the state machine ended in a state
+// that is not terminated, or nil.
+func (m *machine) syntaxError() error {
+	var offset int
+	var msg string
+	switch m.s {
+	case inQuote:
+		offset, msg = m.openStart, "unterminated quoted value"
+	case inBackticks:
+		offset, msg = m.openStart, "unterminated backtick-quoted identifier"
+	case mlcOrMySQLCode, mlcOrMariaDBCode, inMLC, inVersion:
+		offset, msg = m.openStart, "unterminated comment"
+	case inHint:
+		offset, msg = m.openStart, "unterminated optimizer hint"
+	case inValues:
+		if m.parOpen <= 0 {
+			return nil
+		}
+		offset, msg = m.firstPar, "unbalanced parentheses in list of values"
+	default:
+		return nil
+	}
+	return &SyntaxError{Offset: offset, State: stateName[m.s], Msg: msg}
+}
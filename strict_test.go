@@ -0,0 +1,80 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestFingerprintStrict(t *testing.T) {
+	tests := []struct {
+		q      string
+		f      string
+		offset int // -1 if no error
+		state  string
+	}{
+		{"select 'abc", "select", 7, "inQuote"},
+		{"select * from t where a=\"x", "select * from t where a=", 24, "inQuote"},
+		{"select '\\'", "select", 7, "inQuote"},
+		{"select x'0F", "select", 8, "inQuote"},
+		{"select `t", "select", 7, "inBackticks"},
+		{"select 1 /* foo", "select ?", 9, "inMLC"},
+		{"select 1 /*", "select ?", 9, "inMLC"},
+		{"select /*+ BKA(t1) c from t", "select", 7, "inHint"},
+		{"INSERT INTO t VALUES (1, 2), (3, 'x", "insert into t values(?+)", 33, "inQuote"},
+		{"insert into t values (1, (2)", "insert into t values", 21, "inValues"},
+		{"select * from t where id in (1, 2", "select * from t where id in", 28, "inValues"},
+		{"/*!40001 select 1", "/*!40001 select ?", 0, "unknown"},
+		{"select 1 /*M!100100 , 2", "select ? /*m!100100 , ?", 9, "unknown"},
+		{"CALL foo('abc", "call foo", 9, "string"},
+		{"USE `db", "use ?", 4, "ident"},
+		{"use db /* x", "use ?", 7, "comment"},
+
+		{"/*!40001 select 1 */", "/*!40001 select ? */", -1, ""},
+		{"select 'a', `b` from t /* c */", "select ?, `b` from t", -1, ""},
+		{"insert into t values (1),('x)')", "insert into t values(?+)", -1, ""},
+		{"call sp('x')", "call sp", -1, ""},
+		{"use `db`", "use ?", -1, ""},
+		{"administrator command: Quit", "administrator command: Quit", -1, ""},
+	}
+	for _, test := range tests {
+		f, err := query.FingerprintStrict(test.q)
+		if f != test.f {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s\n", test.q, f, test.f)
+		}
+		if test.offset < 0 {
+			if err != nil {
+				t.Errorf("%s: got error: %s", test.q, err)
+			}
+			continue
+		}
+		serr, ok := err.(*query.SyntaxError)
+		if !ok {
+			t.Errorf("%s: got error %v (%T), expected *query.SyntaxError", test.q, err, err)
+			continue
+		}
+		if serr.Offset != test.offset || serr.State != test.state {
+			t.Errorf("%s: got offset %d state %s, expected offset %d state %s", test.q, serr.Offset, serr.State, test.offset, test.state)
+		}
+		// The fingerprint is the same as Fingerprint
+		if f != query.Fingerprint(test.q) {
+			t.Errorf("%s: got %s, Fingerprint returns %s", test.q, f, query.Fingerprint(test.q))
+		}
+	}
+
+	// An unterminated hint is an unterminated comment with StripHints
+	fp := &query.Fingerprinter{StripHints: true}
+	_, err := fp.FingerprintStrict("select /*+ BKA(t1) c from t")
+	if serr, ok := err.(*query.SyntaxError); !ok || serr.Offset != 7 || serr.State != "inMLC" {
+		t.Errorf("StripHints: got error %v, expected unterminated comment at offset 7 (state inMLC)", err)
+	}
+
+	err = &query.SyntaxError{Offset: 7, State: "inQuote", Msg: "unterminated quoted value"}
+	if got, expect := err.Error(), "unterminated quoted value at offset 7 (state inQuote)"; got != expect {
+		t.Errorf("got %s, expected %s", got, expect)
+	}
+}
@@ -0,0 +1,454 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"strings"
+)
+
+// A Table is a table referenced by a query, as returned by Tables. DB and
+// Alias are empty if the query does not qualify or alias the table. Names
+// are unquoted: `db`.`table-1` is DB "db" and Name "table-1".
+type Table struct {
+	DB    string
+	Name  string
+	Alias string
+}
+
+// String returns the table name qualified by the database, if any: db.name.
+func (t Table) String() string {
+	if t.DB == "" {
+		return t.Name
+	}
+	return t.DB + "." + t.Name
+}
+
+// Tables returns the tables referenced by q in the order they first appear,
+// without duplicates. Tables are found after FROM, JOIN, UPDATE, INSERT and
+// REPLACE [INTO], DELETE ... USING, TABLE (CREATE, ALTER, DROP, TRUNCATE,
+// RENAME, LOCK, etc.), and in subqueries and derived tables at any depth.
+// FROM in function arguments like EXTRACT(YEAR FROM d) is ignored. In a
+// multi-table DELETE, tables before FROM, or before USING, are not returned
+// because they refer to the tables that follow. Common table expressions
+// defined by WITH are not tables, so references to them are not returned,
+// but the tables in their definitions are.
+func Tables(q string) []Table {
+	return parseTables(NewLexer(q))
+}
+
+// parseTables returns the tables referenced by the query scanned by l.
+func parseTables(l *Lexer) []Table {
+	p := &tableParser{ctes: map[string]bool{}}
+	for l.nextCode() {
+		p.toks = append(p.toks, l.Token())
+	}
+	p.parse()
+	if len(p.ctes) == 0 {
+		return p.tables
+	}
+	tables := p.tables[:0]
+	for _, t := range p.tables {
+		if t.DB == "" && p.ctes[strings.ToLower(t.Name)] {
+			continue // FROM cte
+		}
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+// tableStopWords cannot be table names or aliases because they are keywords
+// that can follow a table reference, like WHERE in FROM t WHERE.
+var tableStopWords = map[string]bool{
+	"as": true, "cross": true, "except": true, "for": true, "force": true,
+	"from": true, "full": true, "group": true, "having": true, "if": true,
+	"ignore": true, "inner": true, "intersect": true, "into": true,
+	"join": true, "left": true, "like": true, "limit": true, "local": true,
+	"lock": true, "low_priority": true, "natural": true, "on": true,
+	"order": true, "outer": true, "partition": true, "procedure": true,
+	"read": true, "returning": true, "right": true, "select": true,
+	"set": true, "status": true, "straight_join": true, "to": true,
+	"union": true, "use": true, "using": true, "value": true,
+	"values": true, "where": true, "window": true, "with": true,
+	"write": true,
+}
+
+// Kinds of parenthesis in tableParser.parens.
+const (
+	parenExpr     byte = iota // (1 + 2), f(x), (a, b)
+	parenSubquery             // (SELECT ...) in an expression
+	parenTable                // (SELECT ...) AS d or (t1 JOIN t2) in a table list
+)
+
+type tableParser struct {
+	toks    []Token
+	i       int // index of next token
+	tables  []Table
+	stmt    string // first word of current statement, lowercase
+	parens  []byte
+	aliases []bool          // parallel to parens: parenTable allows implicit aliases
+	index   bool            // CREATE/DROP INDEX ... ON t
+	lastRef int             // index of the token after the last table reference
+	ctes    map[string]bool // lowercase names defined by WITH
+}
+
+func (p *tableParser) parse() {
+	for p.i < len(p.toks) {
+		t := p.toks[p.i]
+		p.i++
+
+		if t.Type == TokenOperator {
+			switch t.Text {
+			case "(":
+				kind := parenExpr
+				if w := p.peekWord(0); w == "select" || w == "with" || p.stmt == "" {
+					kind = parenSubquery // or (LOCK TABLES t READ)
+				}
+				p.push(kind, false)
+			case ")":
+				p.pop()
+			case ";":
+				p.stmt = ""
+				p.parens = p.parens[:0]
+				p.aliases = p.aliases[:0]
+				p.index = false
+			}
+			continue
+		}
+		if t.Type != TokenWord {
+			continue
+		}
+
+		word := strings.ToLower(t.Text)
+		start := p.stmt == ""
+		if start {
+			p.stmt = word
+		}
+		if len(p.parens) > 0 && p.parens[len(p.parens)-1] == parenExpr {
+			continue // EXTRACT(YEAR FROM d), etc.
+		}
+		// The statement after WITH cte AS (...)
+		verb := start || (p.stmt == "with" && len(p.parens) == 0)
+
+		switch word {
+		case "with":
+			p.withNames()
+		case "from":
+			if p.stmt == "show" {
+				switch p.prevWord(2) {
+				case "columns", "fields", "index", "indexes", "keys":
+					p.tableList(false) // SHOW COLUMNS FROM t
+				}
+				continue
+			}
+			if p.stmt == "delete" && len(p.parens) == 0 && p.skipToUsing() {
+				continue // DELETE FROM t1 USING t1 JOIN t2
+			}
+			p.tableList(true)
+		case "join", "straight_join":
+			p.tableFactor(true)
+		case "using":
+			if p.stmt == "delete" && !p.peekOp(0, "(") {
+				p.tableList(true)
+			}
+		case "update":
+			if verb {
+				p.skipWords("low_priority", "ignore")
+				p.tableList(true)
+			}
+		case "insert", "replace":
+			if verb {
+				p.skipWords("low_priority", "delayed", "high_priority", "ignore")
+				if p.peekWord(0) != "into" {
+					p.tableFactor(false) // INSERT t VALUES ...
+				}
+			}
+		case "into":
+			switch p.peekWord(0) {
+			case "table", "outfile", "dumpfile":
+			default:
+				if !p.peekOp(0, "@") {
+					p.tableFactor(false)
+				}
+			}
+		case "table", "tables":
+			p.ddlTables(word)
+		case "truncate":
+			if start && p.peekWord(0) != "table" {
+				p.tableFactor(false) // TRUNCATE t
+			}
+		case "index":
+			p.index = p.stmt == "create" || p.stmt == "drop"
+		case "on":
+			if p.index {
+				p.tableFactor(false) // CREATE INDEX i ON t
+				p.index = false
+			}
+		case "like":
+			if p.stmt == "create" && p.lastRef == p.i-1 {
+				p.tableFactor(false) // CREATE TABLE t LIKE t2
+			}
+		}
+	}
+}
+
+// withNames adds the names defined by WITH [RECURSIVE] name [(cols)] AS
+// (...), name2 AS (...) to ctes. It only looks ahead, so the tables in the
+// definitions are parsed as usual.
+func (p *tableParser) withNames() {
+	i := p.i
+	if p.peekWord(0) == "recursive" {
+		i++
+	}
+	for i < len(p.toks) {
+		t := p.toks[i]
+		if t.Type != TokenWord && t.Type != TokenIdent {
+			return
+		}
+		name := strings.ToLower(unquote(t.Text))
+		i = p.skipParens(i + 1) // (cols)
+		if i >= len(p.toks) || p.toks[i].Type != TokenWord || strings.ToLower(p.toks[i].Text) != "as" {
+			return
+		}
+		p.ctes[name] = true
+		i = p.skipParens(i + 1)
+		if i >= len(p.toks) || p.toks[i].Type != TokenOperator || p.toks[i].Text != "," {
+			return
+		}
+		i++
+	}
+}
+
+// skipParens returns the index of the token after the parenthesized tokens
+// that begin at toks[i], or i if toks[i] is not (.
+func (p *tableParser) skipParens(i int) int {
+	depth := 0
+	for j := i; j < len(p.toks); j++ {
+		t := p.toks[j]
+		if t.Type != TokenOperator {
+			if depth == 0 {
+				return i
+			}
+			continue
+		}
+		switch t.Text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		default:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(p.toks)
+}
+
+// ddlTables parses the table(s) after TABLE or TABLES.
+func (p *tableParser) ddlTables(word string) {
+	switch p.stmt {
+	case "show":
+		// SHOW CREATE TABLE t, but not SHOW TABLES or SHOW TABLE STATUS
+		if word == "table" {
+			p.tableFactor(false)
+		}
+		return
+	case "create", "alter":
+		p.skipWords("if", "not", "exists")
+		p.tableFactor(false)
+		return
+	}
+	// DROP TABLE t1, t2; RENAME TABLE a TO b, c TO d; LOCK TABLES t READ,
+	// t2 WRITE; OPTIMIZE TABLE t1, t2; etc.
+	p.skipWords("if", "exists")
+	for {
+		if !p.tableFactor(false) {
+			return
+		}
+		if p.peekWord(0) == "to" {
+			p.i++
+			p.tableFactor(false)
+		}
+		for p.i < len(p.toks) && !p.peekOp(0, ",") && !p.peekOp(0, ";") {
+			p.i++ // READ, WRITE, etc.
+		}
+		if !p.peekOp(0, ",") {
+			return
+		}
+		p.i++
+	}
+}
+
+// tableList parses one or more comma-separated table references.
+func (p *tableParser) tableList(aliases bool) {
+	for p.tableFactor(aliases) && p.peekOp(0, ",") {
+		p.i++
+	}
+}
+
+// tableFactor parses one table reference: a table name with optional alias,
+// or a derived table or parenthesized table list. It returns true if the
+// reference is done and a comma can follow. For parenthesized references,
+// it returns false and parsing resumes when the closing parenthesis is
+// popped.
+func (p *tableParser) tableFactor(aliases bool) bool {
+	if p.peekOp(0, "(") {
+		p.i++
+		p.push(parenTable, aliases) // (SELECT ...) AS d
+		if w := p.peekWord(0); w != "select" && w != "with" {
+			p.tableList(aliases) // (t1 JOIN t2)
+		}
+		return false
+	}
+
+	t, ok := p.tableName()
+	if !ok {
+		return false
+	}
+	if t.DB == "" && strings.ToLower(t.Name) == "dual" {
+		return true
+	}
+	t.Alias = p.alias(aliases)
+	p.lastRef = p.i
+	for _, seen := range p.tables {
+		if seen == t {
+			return true
+		}
+	}
+	p.tables = append(p.tables, t)
+	return true
+}
+
+// tableName parses name, db.name, or name.* (DELETE t.* FROM ...).
+func (p *tableParser) tableName() (Table, bool) {
+	name, ok := p.ident()
+	if !ok {
+		return Table{}, false
+	}
+	t := Table{Name: name}
+	if p.peekOp(0, ".") {
+		p.i++
+		if p.peekOp(0, "*") {
+			p.i++
+		} else if name, ok := p.ident(); ok {
+			t.DB = t.Name
+			t.Name = name
+		}
+	}
+	return t, true
+}
+
+// alias parses AS alias, or an implicit alias if aliases is true.
+func (p *tableParser) alias(aliases bool) string {
+	if p.peekWord(0) == "as" {
+		p.i++
+		alias, _ := p.ident()
+		return alias
+	}
+	if aliases {
+		alias, _ := p.ident()
+		return alias
+	}
+	return ""
+}
+
+// ident parses a word that is not a stop word, or a quoted identifier, and
+// returns it unquoted.
+func (p *tableParser) ident() (string, bool) {
+	if p.i >= len(p.toks) {
+		return "", false
+	}
+	t := p.toks[p.i]
+	switch t.Type {
+	case TokenWord:
+		if tableStopWords[strings.ToLower(t.Text)] {
+			return "", false
+		}
+		p.i++
+		return t.Text, true
+	case TokenIdent:
+		p.i++
+		return unquote(t.Text), true
+	}
+	return "", false
+}
+
+// skipToUsing returns true and advances to USING if the current DELETE has
+// a USING table list; the tables before it are not returned.
+func (p *tableParser) skipToUsing() bool {
+	for i := p.i; i < len(p.toks); i++ {
+		t := p.toks[i]
+		if t.Type == TokenOperator && (t.Text == "(" || t.Text == ";") {
+			return false
+		}
+		if t.Type == TokenWord && strings.ToLower(t.Text) == "using" {
+			p.i = i
+			return true
+		}
+	}
+	return false
+}
+
+func (p *tableParser) skipWords(words ...string) {
+	for wordIn(p.peekWord(0), words...) {
+		p.i++
+	}
+}
+
+func (p *tableParser) push(kind byte, aliases bool) {
+	p.parens = append(p.parens, kind)
+	p.aliases = append(p.aliases, aliases)
+}
+
+func (p *tableParser) pop() {
+	n := len(p.parens)
+	if n == 0 {
+		return
+	}
+	kind, aliases := p.parens[n-1], p.aliases[n-1]
+	p.parens = p.parens[:n-1]
+	p.aliases = p.aliases[:n-1]
+	if kind == parenTable {
+		// (SELECT ...) AS d, t2
+		p.alias(true)
+		if p.peekOp(0, ",") {
+			p.i++
+			p.tableList(aliases)
+		}
+	}
+}
+
+// peekWord returns the lowercase word n tokens ahead, or an empty string if
+// that token is not a word.
+func (p *tableParser) peekWord(n int) string {
+	if p.i+n >= len(p.toks) || p.toks[p.i+n].Type != TokenWord {
+		return ""
+	}
+	return strings.ToLower(p.toks[p.i+n].Text)
+}
+
+// prevWord returns the lowercase word n tokens before p.i, or an empty string.
+func (p *tableParser) prevWord(n int) string {
+	if p.i-n < 0 || p.toks[p.i-n].Type != TokenWord {
+		return ""
+	}
+	return strings.ToLower(p.toks[p.i-n].Text)
+}
+
+func (p *tableParser) peekOp(n int, op string) bool {
+	return p.i+n < len(p.toks) && p.toks[p.i+n].Type == TokenOperator && p.toks[p.i+n].Text == op
+}
+
+// unquote returns a `quoted` identifier without quotes.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != s[len(s)-1] {
+		return s
+	}
+	q := s[0:1]
+	return strings.Replace(s[1:len(s)-1], q+q, q, -1)
+}
@@ -0,0 +1,85 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+// tableString returns tables as "db.name:alias" for easy comparison.
+func tableString(tables []query.Table) string {
+	s := []string{}
+	for _, t := range tables {
+		if t.Alias != "" {
+			s = append(s, t.String()+":"+t.Alias)
+		} else {
+			s = append(s, t.String())
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func TestTables(t *testing.T) {
+	tests := []struct {
+		q      string
+		tables string
+	}{
+		{"SELECT c FROM t WHERE id=1", "t"},
+		{"SELECT 1", ""},
+		{"SELECT 1 FROM DUAL", ""},
+		{"select * from db.t1 a, t2 as b, `db-2`.`table-1` c where a.id=b.id", "db.t1:a t2:b db-2.table-1:c"},
+		{"SELECT * FROM t1 JOIN t2 ON t1.id=t2.id LEFT OUTER JOIN db.t3 x USING (id) STRAIGHT_JOIN t4", "t1 t2 db.t3:x t4"},
+		{"SELECT * FROM t1 INNER JOIN (t2 JOIN t3 ON t2.a=t3.a) ON t1.a=t2.a", "t1 t2 t3"},
+		{"SELECT * FROM t FORCE INDEX (idx) WHERE a=1 ORDER BY b LIMIT 1", "t"},
+		{"SELECT * FROM t1 WHERE id IN (SELECT id FROM t2 WHERE x = (SELECT MAX(x) FROM t3))", "t1 t2 t3"},
+		{"SELECT d.c FROM (SELECT c FROM t1) AS d, t2 WHERE d.c=t2.c", "t1 t2"},
+		{"SELECT EXTRACT(YEAR FROM d), TRIM(LEADING 'x' FROM c) FROM t", "t"},
+		{"SELECT * FROM t1 UNION SELECT * FROM t2", "t1 t2"},
+		{"((LOCK TABLES t1 READ, t2 WRITE))", "t1 t2"},
+		{"SELECT * FROM t1; SELECT * FROM t1 a", "t1 t1:a"},
+		{"SELECT a INTO @a FROM t", "t"},
+		{"WITH cte AS (SELECT id FROM t1) SELECT * FROM cte JOIN t2 USING (id)", "t1 t2"},
+		{"WITH RECURSIVE c1 (n) AS (SELECT 1 UNION ALL SELECT n+1 FROM c1 WHERE n < 5), `c2` AS (SELECT * FROM t1) SELECT * FROM c1, C2, db.c1", "t1 db.c1"},
+		{"SELECT * FROM t1 WHERE id IN (WITH cte AS (SELECT id FROM t2) SELECT id FROM cte)", "t1 t2"},
+		{"WITH cte AS (SELECT id FROM t1) UPDATE t2 JOIN cte USING (id) SET t2.c=1", "t1 t2"},
+		{"INSERT INTO t (a, b) VALUES (1, 2)", "t"},
+		{"INSERT LOW_PRIORITY IGNORE db.t VALUES (1)", "db.t"},
+		{"insert into t1 select * from t2", "t1 t2"},
+		{"REPLACE INTO `t` SET a=1", "t"},
+		{"SELECT REPLACE(c, 'a', 'b') FROM t", "t"},
+		{"UPDATE t SET c=1 WHERE id=2", "t"},
+		{"UPDATE LOW_PRIORITY t1 a, t2 b SET a.c=b.c WHERE a.id=b.id", "t1:a t2:b"},
+		{"UPDATE t1 JOIN t2 ON t1.id=t2.id SET t1.c=t2.c", "t1 t2"},
+		{"INSERT INTO t VALUES (1) ON DUPLICATE KEY UPDATE c=VALUES(c)", "t"},
+		{"SELECT * FROM t WHERE id=1 FOR UPDATE", "t"},
+		{"DELETE FROM t WHERE id=1", "t"},
+		{"DELETE t1, t2 FROM t1 INNER JOIN t2 ON t1.id=t2.id", "t1 t2"},
+		{"DELETE FROM a1, a2 USING t1 AS a1 INNER JOIN t2 AS a2 WHERE a1.id=a2.id", "t1:a1 t2:a2"},
+		{"DELETE FROM t1.*, t2.* USING t1, t2 WHERE t1.id=t2.id", "t1 t2"},
+		{"CREATE TABLE IF NOT EXISTS db.t (id INT)", "db.t"},
+		{"CREATE TABLE t2 LIKE t1", "t2 t1"},
+		{"CREATE TABLE t2 AS SELECT * FROM t1", "t2 t1"},
+		{"CREATE INDEX idx ON t (c)", "t"},
+		{"ALTER TABLE t ADD COLUMN c INT, DROP COLUMN d", "t"},
+		{"DROP TABLE IF EXISTS t1, `t2`", "t1 t2"},
+		{"TRUNCATE TABLE t", "t"},
+		{"TRUNCATE t", "t"},
+		{"RENAME TABLE a TO b, c TO d", "a b c d"},
+		{"LOCK TABLES t1 READ, t2 WRITE", "t1 t2"},
+		{"LOAD DATA INFILE '/tmp/foo.txt' INTO TABLE db.tbl", "db.tbl"},
+		{"SHOW CREATE TABLE t", "t"},
+		{"SHOW TABLES FROM db", ""},
+		{"SHOW COLUMNS FROM t", "t"},
+		{"/* FROM x */ SELECT /*!40001 SQL_NO_CACHE */ * FROM `film`", "film"},
+	}
+	for _, test := range tests {
+		if got := tableString(query.Tables(test.q)); got != test.tables {
+			t.Errorf("%s\ngot:      %s\nexpected: %s", test.q, got, test.tables)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"net/url"
+	"strings"
+)
+
+// FingerprintTags returns the fingerprint of q and the tags in its leading
+// and trailing comments. Tags are key='value' pairs separated by commas, like
+// sqlcommenter (https://google.github.io/sqlcommenter/) appends to queries:
+//
+//	SELECT * FROM t /*action='show',controller='users',traceparent='00-5bd6...-01'*/
+//
+// Keys and values are URL-decoded, and \' in a value is a literal quote. A
+// comment with anything that is not a tag, like /* cron job */, is ignored.
+// Comments in the middle of the query are ignored. If a key is in more than
+// one comment, the last one is returned. Tags is nil if there are none.
+func FingerprintTags(q string) (string, map[string]string) {
+	return defaultFingerprinter.FingerprintTags(q)
+}
+
+// FingerprintTags returns the fingerprint of q and the tags in its leading
+// and trailing comments. Comments and quoted values are scanned with the
+// Dialect and SQLMode of fp. See the package-level FingerprintTags.
+func (fp *Fingerprinter) FingerprintTags(q string) (string, map[string]string) {
+	return fp.Fingerprint(q), commentTags(fp.newLexer(q))
+}
+
+// Tags returns the tags in the leading and trailing comments of q. See
+// FingerprintTags.
+func Tags(q string) map[string]string {
+	return commentTags(NewLexer(q))
+}
+
+// commentTags returns the tags in the leading and trailing comments of the query
+// scanned by l.
+func commentTags(l *Lexer) map[string]string {
+	var leading, trailing []Token
+	code := false
+	for l.Next() {
+		t := l.Token()
+		switch {
+		case t.Type == TokenComment:
+			if code {
+				trailing = append(trailing, t)
+			} else {
+				leading = append(leading, t)
+			}
+		case t.Type == TokenSpace:
+		case t.Type == TokenOperator && t.Text == ";":
+			// SELECT 1 /*tags*/; is a trailing comment
+		default:
+			code = true
+			trailing = trailing[:0]
+		}
+	}
+
+	var tags map[string]string
+	for _, t := range append(leading, trailing...) {
+		kv, ok := parseTags(commentText(t.Text))
+		if !ok {
+			continue
+		}
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		for k, v := range kv {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// commentText returns the text of a comment without /* */, --, or #.
+func commentText(c string) string {
+	switch {
+	case strings.HasPrefix(c, "/*"):
+		c = strings.TrimSuffix(c[2:], "*/")
+	case strings.HasPrefix(c, "--"):
+		c = c[2:]
+	case strings.HasPrefix(c, "#"):
+		c = c[1:]
+	}
+	return strings.TrimSpace(c)
+}
+
+// parseTags parses key='value',key2='value2'. It returns false if s is not
+// only tags.
+func parseTags(s string) (map[string]string, bool) {
+	if s == "" {
+		return nil, false
+	}
+	tags := map[string]string{}
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || eq+1 >= len(s) || s[eq+1] != '\'' {
+			return nil, false
+		}
+		key := strings.TrimSpace(s[:eq])
+		if key == "" || strings.ContainsAny(key, " ,'") {
+			return nil, false
+		}
+
+		// 'value' with \' escapes
+		var val []byte
+		i := eq + 2
+		for ; i < len(s) && s[i] != '\''; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			}
+			val = append(val, s[i])
+		}
+		if i >= len(s) {
+			return nil, false // unterminated value
+		}
+		tags[urlDecode(key)] = urlDecode(string(val))
+
+		s = strings.TrimSpace(s[i+1:])
+		if s == "" {
+			break
+		}
+		if s[0] != ',' {
+			return nil, false
+		}
+		s = strings.TrimSpace(s[1:])
+		if s == "" {
+			return nil, false // trailing comma
+		}
+	}
+	return tags, true
+}
+
+// urlDecode returns s URL-decoded, or s if it is not validly encoded.
+func urlDecode(s string) string {
+	if d, err := url.PathUnescape(s); err == nil {
+		return d
+	}
+	return s
+}
@@ -0,0 +1,92 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestTags(t *testing.T) {
+	tests := []struct {
+		q    string
+		tags map[string]string
+	}{
+		{
+			"SELECT * FROM t WHERE id=1 /*action='show',controller='users',traceparent='00-5bd66ef5095369c7b0d1f8f4bd33716a-c532cb4098ac3dd2-01'*/",
+			map[string]string{
+				"action":      "show",
+				"controller":  "users",
+				"traceparent": "00-5bd66ef5095369c7b0d1f8f4bd33716a-c532cb4098ac3dd2-01",
+			},
+		},
+		{
+			// URL-encoded and escaped
+			"select 1 /*db_driver='django%3A2.2',route='%2Fpolls%201000', name='it\\'s', a%20b='c'*/",
+			map[string]string{
+				"db_driver": "django:2.2",
+				"route":     "/polls 1000",
+				"name":      "it's",
+				"a b":       "c",
+			},
+		},
+		{
+			// Leading and trailing; the last key wins
+			"/* app='web', host='a' */ select 1 -- host='b'\n",
+			map[string]string{"app": "web", "host": "b"},
+		},
+		{
+			// Not tags, and a comment in the middle
+			"/* cron job */ select /* x='1' */ 1 # not='tags',",
+			nil,
+		},
+		{
+			"select 1",
+			nil,
+		},
+	}
+	for _, test := range tests {
+		tags := query.Tags(test.q)
+		if !reflect.DeepEqual(tags, test.tags) {
+			t.Errorf("%s:\ngot tags:\n%v\nexpected:\n%v\n", test.q, tags, test.tags)
+		}
+	}
+}
+
+func TestFingerprintTags(t *testing.T) {
+	q := "/* app='web' */ SELECT * FROM t WHERE id=1 /*controller='users'*/"
+	f, tags := query.FingerprintTags(q)
+	if expect := query.Fingerprint(q); f != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", f, expect)
+	}
+	if expect := map[string]string{"app": "web", "controller": "users"}; !reflect.DeepEqual(tags, expect) {
+		t.Errorf("got %v, expected %v", tags, expect)
+	}
+}
+
+func TestFingerprintTagsOptions(t *testing.T) {
+	tests := []struct {
+		fp *query.Fingerprinter
+		q  string
+	}{
+		{&query.Fingerprinter{SQLMode: query.NoBackslashEscapes}, `SELECT 'C:\' /*app='web'*/`},
+		{&query.Fingerprinter{Dialect: query.DialectPostgreSQL}, `SELECT $$it's$$ /*app='web'*/`},
+	}
+	for _, test := range tests {
+		_, tags := test.fp.FingerprintTags(test.q)
+		if expect := map[string]string{"app": "web"}; !reflect.DeepEqual(tags, expect) {
+			t.Errorf("%s: got %v, expected %v", test.q, tags, expect)
+		}
+	}
+}
+
+func TestTagsSemicolon(t *testing.T) {
+	tags := query.Tags("select 1 /*route='%2Fpolls'*/;")
+	if expect := map[string]string{"route": "/polls"}; !reflect.DeepEqual(tags, expect) {
+		t.Errorf("got %v, expected %v", tags, expect)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ToSelect converts q to an equivalent SELECT that reads the same rows, so
+// the query can be EXPLAINed safely on servers that cannot EXPLAIN DML or on
+// replicas. The conversions are:
+//
+//	UPDATE t SET a=1, b=2 WHERE w ORDER BY o LIMIT n     ->  SELECT a=1, b=2 FROM t WHERE w ORDER BY o LIMIT n
+//	DELETE FROM t WHERE w                                ->  SELECT * FROM t WHERE w
+//	DELETE t1 FROM t1 JOIN t2 ON ... WHERE w             ->  SELECT * FROM t1 JOIN t2 ON ... WHERE w
+//	DELETE FROM t1 USING t1 JOIN t2 ON ... WHERE w       ->  SELECT * FROM t1 JOIN t2 ON ... WHERE w
+//	INSERT INTO t (a) SELECT a FROM t2 ON DUPLICATE ...  ->  SELECT a FROM t2
+//
+// REPLACE ... SELECT is the same as INSERT ... SELECT, and multi-table UPDATE
+// works like single-table UPDATE. A WITH clause before the statement is kept
+// before the SELECT:
+//
+//	WITH c AS (...) UPDATE t JOIN c SET a=1  ->  WITH c AS (...) SELECT a=1 FROM t JOIN c
+//
+// A SELECT is returned unchanged. Anything else, including INSERT ... VALUES,
+// returns an error. Only the first statement in q is converted; see Split.
+func ToSelect(q string) (string, error) {
+	toks, depth := selectTokens(q)
+	if len(toks) == 0 {
+		return "", errors.New("empty query")
+	}
+	typ := Classify(q)
+	if typ == StatementSelect {
+		return between(q, toks, 0, len(toks)), nil
+	}
+	with := ""
+	if wordIn(toks[0].Text, "with") {
+		i := findWord(toks, depth, 1, "update", "delete", "insert", "replace")
+		if i < 0 {
+			return "", fmt.Errorf("cannot convert %s to SELECT", typ)
+		}
+		with = between(q, toks, 0, i) + " "
+		toks, depth = toks[i:], depth[i:]
+	}
+	var s string
+	var err error
+	switch typ {
+	case StatementUpdate:
+		s, err = updateToSelect(q, toks, depth)
+	case StatementDelete:
+		s, err = deleteToSelect(q, toks, depth)
+	case StatementInsert, StatementReplace:
+		s, err = insertToSelect(q, toks, depth)
+	default:
+		err = fmt.Errorf("cannot convert %s to SELECT", typ)
+	}
+	if err != nil {
+		return "", err
+	}
+	return with + s, nil
+}
+
+// selectTokens returns the code tokens of the first statement in q and the
+// parenthesis depth of each.
+func selectTokens(q string) ([]Token, []int) {
+	toks := []Token{}
+	depth := []int{}
+	d := 0
+	l := NewLexer(q)
+	for l.nextCode() {
+		t := l.Token()
+		if t.Type == TokenOperator {
+			switch t.Text {
+			case ";":
+				if d == 0 {
+					return toks, depth
+				}
+			case ")":
+				d--
+			}
+		}
+		toks = append(toks, t)
+		depth = append(depth, d)
+		if t.Type == TokenOperator && t.Text == "(" {
+			d++
+		}
+	}
+	return toks, depth
+}
+
+// findWord returns the index of the first token from i that is one of the
+// words at depth 0, or -1.
+func findWord(toks []Token, depth []int, i int, words ...string) int {
+	for ; i < len(toks); i++ {
+		if depth[i] == 0 && toks[i].Type == TokenWord && wordIn(toks[i].Text, words...) {
+			return i
+		}
+	}
+	return -1
+}
+
+// skipModifiers returns the index of the first token from i that is not one
+// of the words, like LOW_PRIORITY and IGNORE.
+func skipModifiers(toks []Token, i int, words ...string) int {
+	for i < len(toks) && toks[i].Type == TokenWord && wordIn(toks[i].Text, words...) {
+		i++
+	}
+	return i
+}
+
+// between returns q from the start of toks[i] to the end of toks[j-1].
+func between(q string, toks []Token, i, j int) string {
+	if i >= j {
+		return ""
+	}
+	return q[toks[i].Start:toks[j-1].End]
+}
+
+func updateToSelect(q string, toks []Token, depth []int) (string, error) {
+	i := skipModifiers(toks, 1, "low_priority", "ignore")
+	set := findWord(toks, depth, i, "set")
+	if set <= i {
+		return "", errors.New("UPDATE has no table or SET")
+	}
+	rest := findWord(toks, depth, set+1, "where", "order", "limit")
+	if rest < 0 {
+		rest = len(toks)
+	}
+	cols := between(q, toks, set+1, rest)
+	if cols == "" {
+		return "", errors.New("UPDATE has no SET assignments")
+	}
+	s := "SELECT " + cols + " FROM " + between(q, toks, i, set)
+	if rest < len(toks) {
+		s += " " + between(q, toks, rest, len(toks))
+	}
+	return s, nil
+}
+
+func deleteToSelect(q string, toks []Token, depth []int) (string, error) {
+	i := skipModifiers(toks, 1, "low_priority", "quick", "ignore")
+	from := findWord(toks, depth, i, "from")
+	if from < 0 {
+		return "", errors.New("DELETE has no FROM")
+	}
+	refs := from + 1
+	if from == i {
+		// DELETE FROM t1 USING t1 JOIN t2 ...
+		if using := findWord(toks, depth, refs, "using"); using > 0 {
+			refs = using + 1
+		}
+	}
+	tables := between(q, toks, refs, len(toks))
+	if tables == "" {
+		return "", errors.New("DELETE has no tables")
+	}
+	return "SELECT * FROM " + tables, nil
+}
+
+func insertToSelect(q string, toks []Token, depth []int) (string, error) {
+	start := -1
+	for i := 1; i < len(toks) && start < 0; i++ {
+		if depth[i] != 0 {
+			continue
+		}
+		t := toks[i]
+		switch {
+		case t.Type == TokenWord && wordIn(t.Text, "select", "with"):
+			start = i
+		case t.Type == TokenOperator && t.Text == "(" && i+1 < len(toks) && wordIn(toks[i+1].Text, "select", "with"):
+			start = i // INSERT INTO t (SELECT ...)
+		case t.Type == TokenWord && wordIn(t.Text, "values", "value", "set", "table"):
+			return "", fmt.Errorf("cannot convert %s ... %s to SELECT", strings.ToUpper(toks[0].Text), strings.ToUpper(t.Text))
+		}
+	}
+	if start < 0 {
+		return "", fmt.Errorf("%s has no SELECT", strings.ToUpper(toks[0].Text))
+	}
+	end := len(toks)
+	for on := findWord(toks, depth, start, "on"); on > 0; on = findWord(toks, depth, on+1, "on") {
+		if on+1 < len(toks) && wordIn(toks[on+1].Text, "duplicate") {
+			end = on // ON DUPLICATE KEY UPDATE, not JOIN ... ON
+			break
+		}
+	}
+	return between(q, toks, start, end), nil
+}
@@ -0,0 +1,105 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestToSelect(t *testing.T) {
+	tests := []struct {
+		q string
+		s string
+	}{
+		{
+			"UPDATE t SET a=1, b='x' WHERE id=2",
+			"SELECT a=1, b='x' FROM t WHERE id=2",
+		},
+		{
+			"update low_priority ignore db.t set c=(select max(c) from t2 where t2.id=t.id) order by id limit 10;",
+			"SELECT c=(select max(c) from t2 where t2.id=t.id) FROM db.t order by id limit 10",
+		},
+		{
+			"UPDATE t1 JOIN t2 ON t1.id=t2.id SET t1.c=t2.c",
+			"SELECT t1.c=t2.c FROM t1 JOIN t2 ON t1.id=t2.id",
+		},
+		{
+			"DELETE FROM t WHERE id IN (1, 2) LIMIT 1",
+			"SELECT * FROM t WHERE id IN (1, 2) LIMIT 1",
+		},
+		{
+			"DELETE QUICK t1, t2 FROM t1 INNER JOIN t2 INNER JOIN t3 WHERE t1.id=t2.id AND t2.id=t3.id",
+			"SELECT * FROM t1 INNER JOIN t2 INNER JOIN t3 WHERE t1.id=t2.id AND t2.id=t3.id",
+		},
+		{
+			"DELETE FROM t1, t2 USING t1 JOIN t2 ON t1.id=t2.id WHERE t1.c > 0",
+			"SELECT * FROM t1 JOIN t2 ON t1.id=t2.id WHERE t1.c > 0",
+		},
+		{
+			"INSERT INTO t (a, b) SELECT a, b FROM t2 WHERE c=1 ON DUPLICATE KEY UPDATE b=VALUES(b)",
+			"SELECT a, b FROM t2 WHERE c=1",
+		},
+		{
+			"INSERT IGNORE INTO t SELECT t2.* FROM t2 JOIN t3 ON t2.id=t3.id ON DUPLICATE KEY UPDATE c=1",
+			"SELECT t2.* FROM t2 JOIN t3 ON t2.id=t3.id",
+		},
+		{
+			"REPLACE INTO t (SELECT * FROM t2)",
+			"(SELECT * FROM t2)",
+		},
+		{
+			"SELECT c FROM t WHERE id=1",
+			"SELECT c FROM t WHERE id=1",
+		},
+		{
+			"SELECT c FROM t WHERE id=1; DELETE FROM t",
+			"SELECT c FROM t WHERE id=1",
+		},
+		{
+			"DELETE FROM t WHERE id=1; DROP TABLE t",
+			"SELECT * FROM t WHERE id=1",
+		},
+		{
+			"WITH c AS (SELECT 1) UPDATE t JOIN c SET t.a=1",
+			"WITH c AS (SELECT 1) SELECT t.a=1 FROM t JOIN c",
+		},
+		{
+			"WITH RECURSIVE c (n) AS (SELECT 1 UNION SELECT n+1 FROM c WHERE n < 5) DELETE t FROM t JOIN c ON t.id=c.n",
+			"WITH RECURSIVE c (n) AS (SELECT 1 UNION SELECT n+1 FROM c WHERE n < 5) SELECT * FROM t JOIN c ON t.id=c.n",
+		},
+		{
+			"WITH c AS (SELECT 1) SELECT * FROM c;",
+			"WITH c AS (SELECT 1) SELECT * FROM c",
+		},
+	}
+	for _, test := range tests {
+		got, err := query.ToSelect(test.q)
+		if err != nil {
+			t.Errorf("%s: %s", test.q, err)
+			continue
+		}
+		if got != test.s {
+			t.Errorf("got:\n%s\nexpected:\n%s\n", got, test.s)
+		}
+	}
+}
+
+func TestToSelectError(t *testing.T) {
+	queries := []string{
+		"",
+		"INSERT INTO t VALUES (1, (SELECT 2))",
+		"INSERT INTO t SET a=1",
+		"UPDATE t",
+		"DROP TABLE t",
+		"SET @a=1",
+	}
+	for _, q := range queries {
+		if got, err := query.ToSelect(q); err == nil {
+			t.Errorf("%s: got %s, expected an error", q, got)
+		}
+	}
+}
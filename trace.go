@@ -0,0 +1,105 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// A Tracer receives a TraceEvent for every rune of a query as it is
+// fingerprinted. Set Fingerprinter.Tracer to trace a Fingerprinter. Trace is
+// called by the goroutine that is fingerprinting, so a Tracer used by
+// concurrent fingerprints must be safe for concurrent use, like the built-in
+// text and JSON tracers.
+type Tracer interface {
+	Trace(TraceEvent)
+}
+
+// A TraceEvent is one step of the fingerprint state machine: the rune at
+// Offset in the query and how it changed the state. States are the internal
+// state names, like "inWord" and "inQuote". They are meant for debugging and
+// bug reports; they can change between versions.
+type TraceEvent struct {
+	Offset            int    `json:"offset"`             // byte offset of Rune in the query
+	FingerprintOffset int    `json:"fingerprint_offset"` // byte offset in the fingerprint
+	Rune              rune   `json:"rune"`
+	OldState          string `json:"old_state"`
+	NewState          string `json:"new_state"`
+	SQLState          string `json:"sql_state"`        // like "inValues" and "orderBy"
+	CopyFrom          int    `json:"copy_from"`        // offsets of the part of the query to copy,
+	CopyTo            int    `json:"copy_to"`          // before the step
+	Copied            string `json:"copied,omitempty"` // lowercase word copied into the fingerprint
+	Reason            string `json:"reason,omitempty"` // what the step did, like "Quote end"
+}
+
+// NewTextTracer returns a Tracer that writes one line of text per event to w:
+//
+//	0:0 'S' unknown -> inWord (unknown) [0:0]: Random character
+//	6:0 ' ' inWord -> inSpace (unknown) [0:0] copied "select": Word end; Add space
+//
+// Write errors are ignored.
+func NewTextTracer(w io.Writer) Tracer {
+	return &textTracer{w: w}
+}
+
+type textTracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (t *textTracer) Trace(e TraceEvent) {
+	line := fmt.Sprintf("%d:%d %q %s -> %s (%s) [%d:%d]", e.Offset, e.FingerprintOffset, e.Rune, e.OldState, e.NewState, e.SQLState, e.CopyFrom, e.CopyTo)
+	if e.Copied != "" {
+		line += fmt.Sprintf(" copied %q", e.Copied)
+	}
+	if e.Reason != "" {
+		line += ": " + e.Reason
+	}
+	t.mu.Lock()
+	fmt.Fprintln(t.w, line)
+	t.mu.Unlock()
+}
+
+// NewJSONTracer returns a Tracer that writes one JSON object per event to w,
+// with the TraceEvent field names in snake case and Rune as a string:
+//
+//	{"offset":0,"fingerprint_offset":0,"rune":"S","old_state":"unknown",...}
+//
+// Write errors are ignored.
+func NewJSONTracer(w io.Writer) Tracer {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonTracer{enc: enc}
+}
+
+type jsonTracer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (t *jsonTracer) Trace(e TraceEvent) {
+	v := struct {
+		TraceEvent
+		Rune string `json:"rune"`
+	}{e, string(e.Rune)}
+	t.mu.Lock()
+	t.enc.Encode(v)
+	t.mu.Unlock()
+}
+
+// tracer returns the Tracer of fp, or a text tracer to STDOUT if Debug.
+func (fp *Fingerprinter) tracer() Tracer {
+	if fp.Tracer != nil {
+		return fp.Tracer
+	}
+	if fp.Debug {
+		return NewTextTracer(os.Stdout)
+	}
+	return nil
+}
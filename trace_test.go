@@ -0,0 +1,109 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+type recordTracer struct {
+	events []query.TraceEvent
+}
+
+func (t *recordTracer) Trace(e query.TraceEvent) {
+	t.events = append(t.events, e)
+}
+
+func TestTracer(t *testing.T) {
+	rt := &recordTracer{}
+	fp := &query.Fingerprinter{Tracer: rt}
+	q := "SELECT c FROM t WHERE id='x'"
+	if got, expect := fp.Fingerprint(q), "select c from t where id=?"; got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+
+	// One event per rune, plus the space appended to the query
+	if len(rt.events) != len(q)+1 {
+		t.Fatalf("got %d events, expected %d", len(rt.events), len(q)+1)
+	}
+	e := rt.events[6]
+	expect := query.TraceEvent{
+		Offset:   6,
+		Rune:     ' ',
+		OldState: "inWord",
+		NewState: "inSpace",
+		SQLState: "unknown",
+		Copied:   "select",
+		Reason:   "Word end; Add space",
+	}
+	if e != expect {
+		t.Errorf("got:\n%+v\nexpected:\n%+v\n", e, expect)
+	}
+	e = rt.events[27]
+	if e.Offset != 27 || e.OldState != "inQuote" || e.NewState != "unknown" || e.Reason != "Quote end" {
+		t.Errorf("got %+v", e)
+	}
+
+	// FingerprintReader traces the same events
+	rt2 := &recordTracer{}
+	fp.Tracer = rt2
+	var buf bytes.Buffer
+	if err := fp.FingerprintReader(strings.NewReader(q), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(rt2.events) != len(rt.events) {
+		t.Fatalf("FingerprintReader: got %d events, expected %d", len(rt2.events), len(rt.events))
+	}
+	for i := range rt.events {
+		if rt2.events[i] != rt.events[i] {
+			t.Errorf("FingerprintReader: got:\n%+v\nexpected:\n%+v\n", rt2.events[i], rt.events[i])
+		}
+	}
+}
+
+func TestTextTracer(t *testing.T) {
+	var buf bytes.Buffer
+	fp := &query.Fingerprinter{Tracer: query.NewTextTracer(&buf)}
+	fp.Fingerprint("select 1")
+	expect := `0:0 's' unknown -> inWord (unknown) [0:0]: Random character
+1:0 'e' inWord -> inWord (unknown) [0:0]
+2:0 'l' inWord -> inWord (unknown) [0:0]
+3:0 'e' inWord -> inWord (unknown) [0:0]
+4:0 'c' inWord -> inWord (unknown) [0:0]
+5:0 't' inWord -> inWord (unknown) [0:0]
+6:0 ' ' inWord -> inSpace (unknown) [0:0] copied "select": Word end; Add space
+7:7 '1' inSpace -> inNumber (unknown) [7:6]: Number literal
+8:7 ' ' inNumber -> unknown (unknown) [7:7]: Number end; Lost in space; Add space
+`
+	if got := buf.String(); got != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", got, expect)
+	}
+}
+
+func TestJSONTracer(t *testing.T) {
+	var buf bytes.Buffer
+	fp := &query.Fingerprinter{Tracer: query.NewJSONTracer(&buf)}
+	fp.Fingerprint("select 1")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 9 {
+		t.Fatalf("got %d lines, expected 9:\n%s", len(lines), buf.String())
+	}
+	expect := `{"offset":6,"fingerprint_offset":0,"old_state":"inWord","new_state":"inSpace","sql_state":"unknown","copy_from":0,"copy_to":0,"copied":"select","reason":"Word end; Add space","rune":" "}`
+	if lines[6] != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", lines[6], expect)
+	}
+	var e map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[7]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e["rune"] != "1" {
+		t.Errorf("got rune %v, expected \"1\"", e["rune"])
+	}
+}
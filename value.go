@@ -0,0 +1,86 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import "strings"
+
+// A ValueKind is the kind of a literal value replaced in a fingerprint.
+type ValueKind byte
+
+const (
+	ValueNumber ValueKind = iota // 123, -1.5, 1e-9
+	ValueString                  // 'foo' or "foo"
+	ValueHex                     // 0xFF or x'FF'
+	ValueBit                     // 0b01 or b'01'
+	ValueNull                    // NULL
+	ValueList                    // (1, 'a'), (2, 'b') after VALUES or IN
+)
+
+var valueKindName = map[ValueKind]string{
+	ValueNumber: "number",
+	ValueString: "string",
+	ValueHex:    "hex",
+	ValueBit:    "bit",
+	ValueNull:   "null",
+	ValueList:   "list",
+}
+
+func (k ValueKind) String() string {
+	return valueKindName[k]
+}
+
+// A Value is a literal value replaced in a fingerprint, as returned by
+// Parameterize. Text is the value exactly as it appears in the query,
+// including quotes, and Start and End are its byte offsets in the query:
+// q[Start:End] == Text.
+type Value struct {
+	Kind  ValueKind
+	Text  string
+	Start int
+	End   int
+}
+
+func newValue(kind ValueKind, q string, start, end int) Value {
+	return Value{
+		Kind:  kind,
+		Text:  q[start:end],
+		Start: start,
+		End:   end,
+	}
+}
+
+// startsExpr returns true if an expression can start after toks[i], so a
+// following - or + is a sign, like = -1 or (-1, not a minus like a-1. It
+// cannot after a value, an identifier, ), or ], or a word that is not in
+// keywords or is a value like NULL.
+func startsExpr(toks []Token, i int, keywords map[string]bool) bool {
+	if i < 0 {
+		return true
+	}
+	t := toks[i]
+	switch t.Type {
+	case TokenOperator:
+		return t.Text != ")" && t.Text != "]"
+	case TokenWord:
+		word := strings.ToUpper(t.Text)
+		return keywords[word] && !wordIn(word, "null", "true", "false", "end")
+	}
+	return false
+}
+
+// numberKind returns the kind of number literal n: hex (0xFF), bit (0b01),
+// or number.
+func numberKind(n string) ValueKind {
+	n = strings.TrimLeft(n, "+-")
+	if len(n) > 1 && n[0] == '0' {
+		switch n[1] {
+		case 'x':
+			return ValueHex
+		case 'b':
+			return ValueBit
+		}
+	}
+	return ValueNumber
+}
@@ -0,0 +1,69 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Version is a MySQL or MariaDB server version like 8.0.34. The zero value
+// is no version.
+type Version struct {
+	Major   int
+	Minor   int
+	Patch   int
+	MariaDB bool // MariaDB, not MySQL
+}
+
+// ParseVersion parses a server version like "8.0.34", as returned by
+// SELECT VERSION(). A suffix after the numbers, like "-log" in "5.7.44-log",
+// is ignored, except "MariaDB" in a suffix like "10.11.6-MariaDB" sets
+// MariaDB. A missing minor or patch number is zero.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	num := s
+	mariadb := strings.Contains(strings.ToLower(s), "mariadb")
+	if mariadb && strings.HasPrefix(num, "5.5.5-") {
+		// MariaDB 10 and newer prefix the version with 5.5.5- in the
+		// protocol handshake because old clients expect version 5.
+		num = num[len("5.5.5-"):]
+	}
+	if i := strings.IndexFunc(num, func(r rune) bool { return (r < '0' || r > '9') && r != '.' }); i >= 0 {
+		num = num[:i]
+	}
+	parts := strings.Split(num, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version: %s", s)
+	}
+	n := make([]int, 3)
+	for i, p := range parts {
+		d, err := strconv.Atoi(p)
+		if err != nil || d > 99 && i > 0 {
+			return v, fmt.Errorf("invalid version: %s", s)
+		}
+		n[i] = d
+	}
+	v = Version{Major: n[0], Minor: n[1], Patch: n[2]}
+	if v == (Version{}) {
+		return v, fmt.Errorf("invalid version: %s", s)
+	}
+	v.MariaDB = mariadb
+	return v, nil
+}
+
+func (v Version) String() string {
+	if v.MariaDB {
+		return fmt.Sprintf("%d.%d.%d-MariaDB", v.Major, v.Minor, v.Patch)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// number returns the version like in /*!NNNNN MySQL-specific code */:
+// 8.0.34 is 80034, and 10.5.1 is 100501.
+func (v Version) number() int {
+	return v.Major*10000 + v.Minor*100 + v.Patch
+}
@@ -0,0 +1,39 @@
+/*
+	Copyright 2017 Daniel Nichter
+*/
+
+package query_test
+
+import (
+	"testing"
+
+	"github.com/go-mysql/query"
+)
+
+func TestParseVersion(t *testing.T) {
+	expect := map[string]query.Version{
+		"8.0.34":                    {Major: 8, Minor: 0, Patch: 34},
+		"5.7.44-log":                {Major: 5, Minor: 7, Patch: 44},
+		"8.0":                       {Major: 8},
+		"8":                         {Major: 8},
+		"10.11.6-MariaDB":           {Major: 10, Minor: 11, Patch: 6, MariaDB: true},
+		"5.5.5-10.6.12-MariaDB-log": {Major: 10, Minor: 6, Patch: 12, MariaDB: true},
+		"10.5.22-MariaDB-1:10.5.22+maria~ubu2004": {Major: 10, Minor: 5, Patch: 22, MariaDB: true},
+	}
+	for s, v := range expect {
+		got, err := query.ParseVersion(s)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			continue
+		}
+		if got != v {
+			t.Errorf("%s: got %s, expected %s", s, got, v)
+		}
+	}
+
+	for _, s := range []string{"", "x", "8.", "8.0.34.1", "8.100.1", "0.0.0"} {
+		if v, err := query.ParseVersion(s); err == nil {
+			t.Errorf("%s: got %s, expected an error", s, v)
+		}
+	}
+}